@@ -4,8 +4,9 @@ import (
 	"context"
 
 	"github.com/lichb0rn/go-microservices/account/pb"
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
@@ -13,8 +14,8 @@ type Client struct {
 	service pb.AccountServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, cfg grpcclient.Config) (*Client, error) {
+	conn, err := grpcclient.Dial(url, "account", cfg, "GetAccount", "GetAccounts")
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +29,11 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Health fails unless the service reports itself serving.
+func (c *Client) Health(ctx context.Context) error {
+	return health.Downstream(c.conn)(ctx)
+}
+
 func (c *Client) Post(ctx context.Context, name string) (*Account, error) {
 	r, err := c.service.PostAccount(ctx, &pb.PostAccountRequest{Name: name})
 	if err != nil {
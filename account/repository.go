@@ -3,12 +3,17 @@ package account
 import (
 	"context"
 	"database/sql"
+	"errors"
 
-	_ "github.com/lib/pq"
+	"github.com/lichb0rn/go-microservices/events"
+	eventpb "github.com/lichb0rn/go-microservices/events/pb"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
 )
 
 type Repository interface {
 	Close()
+	Ping(ctx context.Context) error
 	Put(ctx context.Context, a Account) error
 	GetById(ctx context.Context, id string) (*Account, error)
 	List(ctx context.Context, skip uint64, take uint64) ([]Account, error)
@@ -19,7 +24,7 @@ type postgresRepository struct {
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := tracing.OpenPostgres(url)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +33,7 @@ func NewPostgresRepository(url string) (Repository, error) {
 		return nil, err
 	}
 
+	metrics.RegisterDB("account", db)
 	return &postgresRepository{db}, nil
 }
 
@@ -35,19 +41,48 @@ func (r *postgresRepository) Close() {
 	r.db.Close()
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
-func (r *postgresRepository) Put(ctx context.Context, a Account) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO accounts (id, name) VALUES ($1, $2)", a.ID, a.Name)
-	return err
+// Put stores the account along with its AccountCreated event in the
+// outbox, in a single transaction.
+func (r *postgresRepository) Put(ctx context.Context, a Account) (err error) {
+	created, err := events.New(events.TypeAccountCreated, events.AccountCreatedVersion, a.ID, &eventpb.AccountCreated{
+		AccountId: a.ID,
+		Name:      a.Name,
+	})
+	if err != nil {
+		return
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO accounts (id, name) VALUES ($1, $2)", a.ID, a.Name)
+	if err != nil {
+		return
+	}
+	err = events.WriteOutbox(ctx, tx, created)
+	return
 }
 
 func (r *postgresRepository) GetById(ctx context.Context, id string) (*Account, error) {
 	row := r.db.QueryRowContext(ctx, "SELECT id, name FROM accounts WHERE id = $1", id)
 	a := &Account{}
 	if err := row.Scan(&a.ID, &a.Name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return a, nil
@@ -3,34 +3,40 @@ package account
 
 import (
 	"context"
-	"fmt"
-	"net"
 
 	"github.com/lichb0rn/go-microservices/account/pb"
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/grpcserver"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
 )
 
+// errorCodes maps the account errors to the codes they are sent with.
+var errorCodes = grpcerr.Codes{
+	ErrNotFound:       codes.NotFound,
+	ErrInvalidAccount: codes.InvalidArgument,
+}
+
 type grpcServer struct {
 	pb.UnimplementedAccountServiceServer
 	service Service
 }
 
-func ListendGRPC(s Service, port int) error {
-	lis, err := net.Listen("tcp", ":"+fmt.Sprint(port))
-	if err != nil {
-		return err
-	}
-	server := grpc.NewServer()
+func ListendGRPC(s Service, checker *health.Checker, chain interceptor.Chain, port int) (*grpcserver.Server, error) {
+	server := grpc.NewServer(append(chain.ServerOptions(), grpcserver.KeepaliveEnforcement())...)
 	pb.RegisterAccountServiceServer(server, &grpcServer{service: s})
+	checker.Register(server)
 	reflection.Register(server)
-	return server.Serve(lis)
+	return grpcserver.New(server, checker, port)
 }
 
 func (s *grpcServer) PostAccount(ctx context.Context, r *pb.PostAccountRequest) (*pb.PostAccountResponse, error) {
 	acc, err := s.service.Post(ctx, r.Name)
 	if err != nil {
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "account not created")
 	}
 	return &pb.PostAccountResponse{Account: &pb.Account{Id: acc.ID, Name: acc.Name}}, nil
 }
@@ -38,7 +44,7 @@ func (s *grpcServer) PostAccount(ctx context.Context, r *pb.PostAccountRequest)
 func (s *grpcServer) GetAccount(ctx context.Context, r *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
 	acc, err := s.service.GetOne(ctx, r.Id)
 	if err != nil {
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "account not found")
 	}
 	return &pb.GetAccountResponse{Account: &pb.Account{Id: acc.ID, Name: acc.Name}}, nil
 }
@@ -46,7 +52,7 @@ func (s *grpcServer) GetAccount(ctx context.Context, r *pb.GetAccountRequest) (*
 func (s *grpcServer) GetAccounts(ctx context.Context, r *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
 	accs, err := s.service.GetMany(ctx, r.Skip, r.Take)
 	if err != nil {
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "accounts not found")
 	}
 
 	accounts := make([]*pb.Account, 0, len(accs))
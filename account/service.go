@@ -2,10 +2,24 @@ package account
 
 import (
 	"context"
+	"errors"
+	"strings"
 
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/metrics"
 	"github.com/segmentio/ksuid"
 )
 
+var (
+	ErrNotFound       = errors.New("account not found")
+	ErrInvalidAccount = errors.New("invalid account")
+)
+
+var accountsCreated = metrics.NewCounter("accounts_created_total", "Accounts created.")
+
+// maxNameLength is the length of the accounts.name column.
+const maxNameLength = 24
+
 type Service interface {
 	Post(ctx context.Context, name string) (*Account, error)
 	GetOne(ctx context.Context, id string) (*Account, error)
@@ -26,6 +40,14 @@ func NewService(r Repository) Service {
 }
 
 func (s *accountService) Post(ctx context.Context, name string) (*Account, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return nil, grpcerr.Invalid(ErrInvalidAccount, "name", "must not be empty")
+	case len([]rune(name)) > maxNameLength:
+		return nil, grpcerr.Invalid(ErrInvalidAccount, "name", "must be at most 24 characters")
+	}
+
 	a := &Account{
 		Name: name,
 		ID:   ksuid.New().String(),
@@ -34,6 +56,7 @@ func (s *accountService) Post(ctx context.Context, name string) (*Account, error
 	if err := s.repository.Put(ctx, *a); err != nil {
 		return nil, err
 	}
+	accountsCreated.Inc()
 
 	return a, nil
 }
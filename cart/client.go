@@ -0,0 +1,131 @@
+package cart
+
+import (
+	"context"
+
+	"github.com/lichb0rn/go-microservices/cart/pb"
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/order"
+	"google.golang.org/grpc"
+)
+
+type Client struct {
+	conn    *grpc.ClientConn
+	service pb.CartServiceClient
+}
+
+func NewClient(url string, cfg grpcclient.Config) (*Client, error) {
+	conn, err := grpcclient.Dial(url, "cart", cfg, "GetCart")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:    conn,
+		service: pb.NewCartServiceClient(conn),
+	}, nil
+}
+
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// Health fails unless the service reports itself serving.
+func (c *Client) Health(ctx context.Context) error {
+	return health.Downstream(c.conn)(ctx)
+}
+
+func (c *Client) AddItem(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error) {
+	r, err := c.service.AddItem(ctx, &pb.AddItemRequest{
+		Owner:     ownerToProto(owner),
+		ProductId: productId,
+		Quantity:  uint32(quantity),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cart := cartFromProto(r.Cart)
+	return &cart, nil
+}
+
+func (c *Client) UpdateQuantity(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error) {
+	r, err := c.service.UpdateQuantity(ctx, &pb.UpdateQuantityRequest{
+		Owner:     ownerToProto(owner),
+		ProductId: productId,
+		Quantity:  uint32(quantity),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cart := cartFromProto(r.Cart)
+	return &cart, nil
+}
+
+func (c *Client) RemoveItem(ctx context.Context, owner Owner, productId string) (*Cart, error) {
+	r, err := c.service.RemoveItem(ctx, &pb.RemoveItemRequest{
+		Owner:     ownerToProto(owner),
+		ProductId: productId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cart := cartFromProto(r.Cart)
+	return &cart, nil
+}
+
+func (c *Client) GetCart(ctx context.Context, owner Owner) (*Cart, error) {
+	r, err := c.service.GetCart(ctx, &pb.GetCartRequest{Owner: ownerToProto(owner)})
+	if err != nil {
+		return nil, err
+	}
+	cart := cartFromProto(r.Cart)
+	return &cart, nil
+}
+
+// Checkout places an order for the cart and returns its id.
+func (c *Client) Checkout(ctx context.Context, owner Owner, opts order.PostOptions) (string, error) {
+	a := opts.ShippingAddress
+	r, err := c.service.Checkout(ctx, &pb.CheckoutRequest{
+		Owner:          ownerToProto(owner),
+		IdempotencyKey: opts.IdempotencyKey,
+		Coupon:         opts.Coupon,
+		ShippingAddress: &pb.ShippingAddress{
+			Line1:      a.Line1,
+			Line2:      a.Line2,
+			City:       a.City,
+			Region:     a.Region,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+		},
+		ShippingMethod: opts.ShippingMethod,
+	})
+	if err != nil {
+		return "", err
+	}
+	return r.OrderId, nil
+}
+
+func ownerToProto(o Owner) *pb.Owner {
+	return &pb.Owner{AccountId: o.AccountID, SessionId: o.SessionID}
+}
+
+func cartFromProto(pc *pb.Cart) Cart {
+	c := Cart{
+		ID:         pc.Id,
+		AccountID:  pc.AccountId,
+		SessionID:  pc.SessionId,
+		TotalPrice: pc.TotalPrice,
+	}
+	c.UpdatedAt.UnmarshalBinary(pc.UpdatedAt)
+
+	for _, i := range pc.Items {
+		c.Items = append(c.Items, Item{
+			ProductID:   i.ProductId,
+			Name:        i.Name,
+			Description: i.Description,
+			Price:       i.Price,
+			Quantity:    int(i.Quantity),
+		})
+	}
+	return c
+}
@@ -0,0 +1,194 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
+	"github.com/segmentio/ksuid"
+)
+
+type Repository interface {
+	Close()
+	Ping(ctx context.Context) error
+	// GetOrCreate returns the cart of the owner with its stored lines,
+	// creating an empty one on first use.
+	GetOrCreate(ctx context.Context, owner Owner) (*Cart, error)
+	// AddItem adds quantity to the line of the product, creating it if needed.
+	AddItem(ctx context.Context, cartId, productId string, quantity int) error
+	// SetQuantity fails with ErrItemNotFound if the product is not in the cart.
+	SetQuantity(ctx context.Context, cartId, productId string, quantity int) error
+	RemoveItem(ctx context.Context, cartId, productId string) error
+	Clear(ctx context.Context, cartId string) error
+}
+
+type postgresRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRepository(url string) (Repository, error) {
+	db, err := tracing.OpenPostgres(url)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RegisterDB("cart", db)
+	return &postgresRepository{db}, nil
+}
+
+func (r *postgresRepository) Close() {
+	r.db.Close()
+}
+
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *postgresRepository) GetOrCreate(ctx context.Context, owner Owner) (*Cart, error) {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO carts (id, account_id, session_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT DO NOTHING`,
+		ksuid.New().String(),
+		nullString(owner.AccountID),
+		nullString(owner.SessionID),
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT
+		c.id,
+		c.account_id,
+		c.session_id,
+		c.updated_at,
+		i.product_id,
+		i.quantity
+		FROM carts c LEFT JOIN cart_items i ON (c.id = i.cart_id)
+		WHERE c.account_id = $1 OR c.session_id = $2
+		ORDER BY i.added_at, i.product_id`,
+		nullString(owner.AccountID),
+		nullString(owner.SessionID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	c := &Cart{}
+	for rows.Next() {
+		var accountId, sessionId, productId sql.NullString
+		var quantity sql.NullInt64
+		if err := rows.Scan(
+			&c.ID,
+			&accountId,
+			&sessionId,
+			&c.UpdatedAt,
+			&productId,
+			&quantity,
+		); err != nil {
+			return nil, err
+		}
+		c.AccountID = accountId.String
+		c.SessionID = sessionId.String
+
+		if productId.Valid {
+			c.Items = append(c.Items, Item{
+				ProductID: productId.String,
+				Quantity:  int(quantity.Int64),
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *postgresRepository) AddItem(ctx context.Context, cartId, productId string, quantity int) error {
+	return r.change(ctx, cartId,
+		`INSERT INTO cart_items (cart_id, product_id, quantity, added_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`,
+		cartId, productId, quantity,
+	)
+}
+
+func (r *postgresRepository) SetQuantity(ctx context.Context, cartId, productId string, quantity int) error {
+	return r.change(ctx, cartId,
+		`UPDATE cart_items SET quantity = $3 WHERE cart_id = $1 AND product_id = $2`,
+		cartId, productId, quantity,
+	)
+}
+
+func (r *postgresRepository) RemoveItem(ctx context.Context, cartId, productId string) error {
+	err := r.change(ctx, cartId,
+		`DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2`,
+		cartId, productId,
+	)
+	if err == ErrItemNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *postgresRepository) Clear(ctx context.Context, cartId string) error {
+	err := r.change(ctx, cartId, `DELETE FROM cart_items WHERE cart_id = $1`, cartId)
+	if err == ErrItemNotFound {
+		return nil
+	}
+	return err
+}
+
+// change runs a statement on the lines of a cart and bumps the cart's
+// updated_at in the same transaction. It fails with ErrItemNotFound if no
+// line was affected.
+func (r *postgresRepository) change(ctx context.Context, cartId string, query string, args ...any) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = ErrItemNotFound
+		return
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE carts SET updated_at = $1 WHERE id = $2`,
+		time.Now().UTC(),
+		cartId,
+	)
+	return
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,135 @@
+//go:generate protoc --go_out=./ --go-grpc_out=./ cart.proto
+package cart
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/lichb0rn/go-microservices/cart/pb"
+	"github.com/lichb0rn/go-microservices/grpcserver"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
+	"github.com/lichb0rn/go-microservices/order"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type grpcServer struct {
+	pb.UnimplementedCartServiceServer
+	service Service
+}
+
+func ListendGRPC(s Service, checker *health.Checker, chain interceptor.Chain, port int) (*grpcserver.Server, error) {
+	server := grpc.NewServer(append(chain.ServerOptions(), grpcserver.KeepaliveEnforcement())...)
+	pb.RegisterCartServiceServer(server, &grpcServer{service: s})
+	checker.Register(server)
+	reflection.Register(server)
+	return grpcserver.New(server, checker, port)
+}
+
+func (s *grpcServer) AddItem(ctx context.Context, r *pb.AddItemRequest) (*pb.CartResponse, error) {
+	c, err := s.service.AddItem(ctx, ownerFromProto(r.Owner), r.ProductId, int(r.Quantity))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CartResponse{Cart: cartToProto(*c)}, nil
+}
+
+func (s *grpcServer) UpdateQuantity(ctx context.Context, r *pb.UpdateQuantityRequest) (*pb.CartResponse, error) {
+	c, err := s.service.UpdateQuantity(ctx, ownerFromProto(r.Owner), r.ProductId, int(r.Quantity))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CartResponse{Cart: cartToProto(*c)}, nil
+}
+
+func (s *grpcServer) RemoveItem(ctx context.Context, r *pb.RemoveItemRequest) (*pb.CartResponse, error) {
+	c, err := s.service.RemoveItem(ctx, ownerFromProto(r.Owner), r.ProductId)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CartResponse{Cart: cartToProto(*c)}, nil
+}
+
+func (s *grpcServer) GetCart(ctx context.Context, r *pb.GetCartRequest) (*pb.CartResponse, error) {
+	c, err := s.service.GetCart(ctx, ownerFromProto(r.Owner))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CartResponse{Cart: cartToProto(*c)}, nil
+}
+
+func (s *grpcServer) Checkout(ctx context.Context, r *pb.CheckoutRequest) (*pb.CheckoutResponse, error) {
+	orderId, err := s.service.Checkout(ctx, ownerFromProto(r.Owner), order.PostOptions{
+		IdempotencyKey:  r.IdempotencyKey,
+		Coupon:          r.Coupon,
+		ShippingAddress: addressFromProto(r.ShippingAddress),
+		ShippingMethod:  r.ShippingMethod,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CheckoutResponse{OrderId: orderId}, nil
+}
+
+// toStatus maps cart errors to gRPC codes. Errors coming back from the
+// order service already carry a status and are passed through.
+func toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidOwner), errors.Is(err, ErrInvalidQuantity):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrProductNotFound), errors.Is(err, ErrItemNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrEmptyCart), errors.Is(err, ErrAccountRequired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if _, ok := status.FromError(err); !ok {
+		slog.ErrorContext(ctx, "unexpected error", "error", err)
+	}
+	return err
+}
+
+func ownerFromProto(o *pb.Owner) Owner {
+	if o == nil {
+		return Owner{}
+	}
+	return Owner{AccountID: o.AccountId, SessionID: o.SessionId}
+}
+
+func addressFromProto(a *pb.ShippingAddress) order.Address {
+	if a == nil {
+		return order.Address{}
+	}
+	return order.Address{
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+func cartToProto(c Cart) *pb.Cart {
+	pc := &pb.Cart{
+		Id:         c.ID,
+		AccountId:  c.AccountID,
+		SessionId:  c.SessionID,
+		TotalPrice: c.TotalPrice,
+	}
+	pc.UpdatedAt, _ = c.UpdatedAt.MarshalBinary()
+
+	for _, i := range c.Items {
+		pc.Items = append(pc.Items, &pb.Cart_Item{
+			ProductId:   i.ProductID,
+			Name:        i.Name,
+			Description: i.Description,
+			Price:       i.Price,
+			Quantity:    uint32(i.Quantity),
+		})
+	}
+	return pc
+}
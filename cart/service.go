@@ -0,0 +1,241 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/order"
+)
+
+var (
+	ErrInvalidOwner    = errors.New("cart owner must be either an account or a session")
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	ErrProductNotFound = errors.New("product not found")
+	ErrEmptyCart       = errors.New("cart is empty")
+	ErrAccountRequired = errors.New("an account is required to check out")
+	ErrItemNotFound    = errors.New("product is not in the cart")
+)
+
+// Owner identifies a cart. Exactly one of the fields is set: signed in
+// customers shop with their account, anonymous ones with a session id.
+type Owner struct {
+	AccountID string
+	SessionID string
+}
+
+func (o Owner) validate() error {
+	if (o.AccountID == "") == (o.SessionID == "") {
+		return ErrInvalidOwner
+	}
+	return nil
+}
+
+// Cart holds what the owner is about to buy. Only product ids and
+// quantities are stored; names and prices come from the catalog every time
+// the cart is read.
+type Cart struct {
+	ID         string
+	AccountID  string
+	SessionID  string
+	Items      []Item
+	TotalPrice float64
+	UpdatedAt  time.Time
+}
+
+type Item struct {
+	ProductID   string
+	Name        string
+	Description string
+	Price       float64
+	Quantity    int
+}
+
+type Service interface {
+	AddItem(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error)
+	// UpdateQuantity sets the quantity of a line. Zero removes it.
+	UpdateQuantity(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error)
+	RemoveItem(ctx context.Context, owner Owner, productId string) (*Cart, error)
+	GetCart(ctx context.Context, owner Owner) (*Cart, error)
+	// Checkout places an order for the cart and empties it, returning the
+	// order id.
+	Checkout(ctx context.Context, owner Owner, opts order.PostOptions) (string, error)
+}
+
+type cartService struct {
+	repository    Repository
+	catalogClient *catalog.Client
+	orderClient   *order.Client
+}
+
+func NewService(r Repository, catalogClient *catalog.Client, orderClient *order.Client) Service {
+	return &cartService{
+		repository:    r,
+		catalogClient: catalogClient,
+		orderClient:   orderClient,
+	}
+}
+
+func (s *cartService) AddItem(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if err := s.checkProduct(ctx, productId); err != nil {
+		return nil, err
+	}
+
+	c, err := s.repository.GetOrCreate(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.AddItem(ctx, c.ID, productId, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, owner)
+}
+
+func (s *cartService) UpdateQuantity(ctx context.Context, owner Owner, productId string, quantity int) (*Cart, error) {
+	if quantity < 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if quantity == 0 {
+		return s.RemoveItem(ctx, owner, productId)
+	}
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	c, err := s.repository.GetOrCreate(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.SetQuantity(ctx, c.ID, productId, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, owner)
+}
+
+func (s *cartService) RemoveItem(ctx context.Context, owner Owner, productId string) (*Cart, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	c, err := s.repository.GetOrCreate(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.RemoveItem(ctx, c.ID, productId); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, owner)
+}
+
+// GetCart returns the cart priced from the catalog. Lines whose product is
+// no longer in the catalog are left out.
+func (s *cartService) GetCart(ctx context.Context, owner Owner) (*Cart, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	c, err := s.repository.GetOrCreate(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.price(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Checkout hands the cart over to the order service. Without an explicit
+// key the cart id and its last change are used, so that submitting the same
+// cart twice places a single order.
+func (s *cartService) Checkout(ctx context.Context, owner Owner, opts order.PostOptions) (string, error) {
+	if err := owner.validate(); err != nil {
+		return "", err
+	}
+	if owner.AccountID == "" {
+		return "", ErrAccountRequired
+	}
+
+	c, err := s.GetCart(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	if len(c.Items) == 0 {
+		return "", ErrEmptyCart
+	}
+
+	if opts.IdempotencyKey == "" {
+		opts.IdempotencyKey = fmt.Sprintf("cart:%s:%d", c.ID, c.UpdatedAt.UnixNano())
+	}
+
+	products := make([]order.OrderedProduct, 0, len(c.Items))
+	for _, i := range c.Items {
+		products = append(products, order.OrderedProduct{
+			ID:       i.ProductID,
+			Quantity: i.Quantity,
+		})
+	}
+	o, err := s.orderClient.Post(ctx, owner.AccountID, products, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repository.Clear(ctx, c.ID); err != nil {
+		return "", err
+	}
+	return o.ID, nil
+}
+
+func (s *cartService) checkProduct(ctx context.Context, productId string) error {
+	products, err := s.catalogClient.GetProducts(ctx, 0, 0, []string{productId}, "")
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+func (s *cartService) price(ctx context.Context, c *Cart) error {
+	if len(c.Items) == 0 {
+		return nil
+	}
+
+	productIds := make([]string, 0, len(c.Items))
+	for _, i := range c.Items {
+		productIds = append(productIds, i.ProductID)
+	}
+	products, err := s.catalogClient.GetProducts(ctx, 0, 0, productIds, "")
+	if err != nil {
+		return err
+	}
+
+	byId := make(map[string]catalog.Product, len(products))
+	for _, p := range products {
+		byId[p.ID] = p
+	}
+
+	items := make([]Item, 0, len(c.Items))
+	c.TotalPrice = 0
+	for _, i := range c.Items {
+		p, ok := byId[i.ProductID]
+		if !ok {
+			continue
+		}
+		i.Name = p.Name
+		i.Description = p.Description
+		i.Price = p.Price
+		c.TotalPrice += p.Price * float64(i.Quantity)
+		items = append(items, i)
+	}
+	c.Items = items
+	return nil
+}
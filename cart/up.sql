@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS carts (
+  id CHAR(27) PRIMARY KEY,
+  account_id CHAR(27) UNIQUE,
+  session_id VARCHAR(64) UNIQUE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  CHECK ((account_id IS NULL) <> (session_id IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+  cart_id CHAR(27) NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+  product_id CHAR(27) NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  added_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (cart_id, product_id)
+);
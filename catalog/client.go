@@ -4,8 +4,9 @@ import (
 	"context"
 
 	"github.com/lichb0rn/go-microservices/catalog/pb"
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
@@ -13,8 +14,8 @@ type Client struct {
 	service pb.CatalogServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, cfg grpcclient.Config) (*Client, error) {
+	conn, err := grpcclient.Dial(url, "catalog", cfg, "GetProduct", "GetProducts")
 	if err != nil {
 		return nil, err
 	}
@@ -28,12 +29,17 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
-func (c *Client) PostProduct(ctx context.Context, name, description string, price float64) (*Product, error) {
-	r, err := c.service.PostProduct(ctx, &pb.PostProductRequest{Name: name, Description: description, Price: price})
+// Health fails unless the service reports itself serving.
+func (c *Client) Health(ctx context.Context) error {
+	return health.Downstream(c.conn)(ctx)
+}
+
+func (c *Client) PostProduct(ctx context.Context, name, description string, price float64, taxClass string, weight float64) (*Product, error) {
+	r, err := c.service.PostProduct(ctx, &pb.PostProductRequest{Name: name, Description: description, Price: price, TaxClass: taxClass, Weight: weight})
 	if err != nil {
 		return nil, err
 	}
-	return &Product{ID: r.Product.Id, Name: r.Product.Name, Description: r.Product.Description, Price: r.Product.Price}, nil
+	return &Product{ID: r.Product.Id, Name: r.Product.Name, Description: r.Product.Description, Price: r.Product.Price, TaxClass: r.Product.TaxClass, Weight: r.Product.Weight}, nil
 }
 
 func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
@@ -41,7 +47,7 @@ func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Product{ID: r.Product.Id, Name: r.Product.Name, Description: r.Product.Description, Price: r.Product.Price}, nil
+	return &Product{ID: r.Product.Id, Name: r.Product.Name, Description: r.Product.Description, Price: r.Product.Price, TaxClass: r.Product.TaxClass, Weight: r.Product.Weight}, nil
 }
 
 func (c *Client) GetProducts(ctx context.Context, skip uint64, take uint64, ids []string, query string) ([]Product, error) {
@@ -52,7 +58,7 @@ func (c *Client) GetProducts(ctx context.Context, skip uint64, take uint64, ids
 
 	products := make([]Product, 0, len(r.Products))
 	for _, p := range r.Products {
-		products = append(products, Product{ID: p.Id, Name: p.Name, Description: p.Description, Price: p.Price})
+		products = append(products, Product{ID: p.Id, Name: p.Name, Description: p.Description, Price: p.Price, TaxClass: p.TaxClass, Weight: p.Weight})
 	}
 	return products, nil
 }
@@ -1,16 +1,42 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/events"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
 	"github.com/tinrab/kit/retry"
 )
 
 type Config struct {
-	DatabaseURL string `envconfig:"DATABASE_URL"`
+	DatabaseURL  string `envconfig:"DATABASE_URL"`
+	EventBusURL  string `envconfig:"EVENT_BUS_URL"`
+	EventChannel string `envconfig:"EVENT_CHANNEL" default:"events"`
+	// RequestTimeout bounds every call the service serves.
+	RequestTimeout time.Duration `envconfig:"REQUEST_TIMEOUT" default:"10s"`
+	// HealthInterval is how often the dependencies are checked.
+	HealthInterval time.Duration `envconfig:"HEALTH_INTERVAL" default:"5s"`
+	// ShutdownTimeout is how long the calls in flight get to finish on shutdown.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"8s"`
+	TraceExporter   string        `envconfig:"TRACE_EXPORTER" default:"none"`
+	// LogFormat is json or text, and LogLevel debug, info, warn or error.
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+	// MetricsAddr is where /metrics is served, apart from the gRPC port.
+	MetricsAddr string `envconfig:"METRICS_ADDR" default:":9090"`
 }
 
 func main() {
@@ -20,17 +46,68 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := logging.Setup("catalog", cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	exporter, err := tracing.NewExporter(cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracing.Configure("catalog", exporter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var repository catalog.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
 		repository, err = catalog.NewElasticRepository(cfg.DatabaseURL)
 		if err != nil {
-			log.Println(err)
+			slog.WarnContext(ctx, "repository not ready, retrying", "error", err)
 		}
 		return
 	})
 
 	defer repository.Close()
-	log.Println("Listening on port 8080")
-	s := catalog.NewService(repository)
-	log.Fatal(catalog.ListendGRPC(s, 8080))
+
+	bus, err := events.Open(cfg.EventBusURL, cfg.EventChannel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bus.Close()
+
+	checker := health.NewChecker(map[string]health.Check{
+		"elasticsearch": repository.Ping,
+	})
+
+	// The background loops end before anything deferred above is closed.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := metrics.ListenAndServe(ctx, cfg.MetricsAddr); err != nil {
+			slog.ErrorContext(ctx, "metrics not served", "error", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checker.Run(ctx, cfg.HealthInterval)
+	}()
+
+	s := catalog.NewService(repository, catalog.WithEventBus(bus))
+	srv, err := catalog.ListendGRPC(s, checker, interceptor.Default(cfg.RequestTimeout), 8080)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.InfoContext(ctx, "listening", "port", 8080)
+	if err := srv.Run(ctx, cfg.ShutdownTimeout); err != nil {
+		slog.ErrorContext(ctx, "server stopped", "error", err)
+	}
+	slog.InfoContext(ctx, "shutting down")
+	stop()
 }
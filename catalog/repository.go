@@ -4,17 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"net/http"
 
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
 	elastic "gopkg.in/olivere/elastic.v5"
 )
 
 var (
-	ErrNotFound = errors.New("Entity not found")
+	ErrNotFound         = errors.New("product not found")
+	ErrClusterUnhealthy = errors.New("elasticsearch cluster is red")
 )
 
 type Repository interface {
 	Close()
+	// Ping fails unless the cluster is at least yellow: every primary shard
+	// is allocated.
+	Ping(ctx context.Context) error
 	Put(ctx context.Context, p Product) error
 	GetById(ctx context.Context, id string) (*Product, error)
 	List(ctx context.Context, skip uint64, take uint64) ([]Product, error)
@@ -30,6 +37,8 @@ type productDocument struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	TaxClass    string  `json:"tax_class"`
+	Weight      float64 `json:"weight"`
 }
 
 func NewElasticRepository(url string) (Repository, error) {
@@ -37,6 +46,7 @@ func NewElasticRepository(url string) (Repository, error) {
 		elastic.SetURL(url),
 		elastic.SetSniff(false),
 		elastic.SetBasicAuth("username", "password"),
+		elastic.SetHttpClient(&http.Client{Transport: tracing.Transport(metrics.Transport("elasticsearch", nil))}),
 	)
 	if err != nil {
 		return nil, err
@@ -50,6 +60,17 @@ func (r *elasticRepository) Close() {
 	// this is just the method for consistency
 }
 
+func (r *elasticRepository) Ping(ctx context.Context) error {
+	res, err := r.client.ClusterHealth().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if res.Status == "red" {
+		return ErrClusterUnhealthy
+	}
+	return nil
+}
+
 func (r *elasticRepository) Put(ctx context.Context, p Product) error {
 	_, err := r.client.Index().
 		Index("catalog").
@@ -59,6 +80,8 @@ func (r *elasticRepository) Put(ctx context.Context, p Product) error {
 			Name:        p.Name,
 			Description: p.Description,
 			Price:       p.Price,
+			TaxClass:    p.TaxClass,
+			Weight:      p.Weight,
 		}).
 		Do(ctx)
 
@@ -71,6 +94,9 @@ func (r *elasticRepository) GetById(ctx context.Context, id string) (*Product, e
 		Type("product").
 		Id(id).
 		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +114,8 @@ func (r *elasticRepository) GetById(ctx context.Context, id string) (*Product, e
 		Name:        p.Name,
 		Description: p.Description,
 		Price:       p.Price,
+		TaxClass:    taxClass(p.TaxClass),
+		Weight:      p.Weight,
 	}, err
 }
 
@@ -101,7 +129,7 @@ func (r *elasticRepository) List(ctx context.Context, skip uint64, take uint64)
 		Do(ctx)
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "elasticsearch query failed", "error", err)
 		return nil, err
 	}
 
@@ -114,6 +142,8 @@ func (r *elasticRepository) List(ctx context.Context, skip uint64, take uint64)
 				Name:        p.Name,
 				Description: p.Description,
 				Price:       p.Price,
+				TaxClass:    taxClass(p.TaxClass),
+				Weight:      p.Weight,
 			})
 		}
 
@@ -134,7 +164,7 @@ func (r *elasticRepository) ListWithIDs(ctx context.Context, ids []string) ([]Pr
 		Add(items...).
 		Do(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "elasticsearch query failed", "error", err)
 		return nil, err
 	}
 
@@ -147,6 +177,8 @@ func (r *elasticRepository) ListWithIDs(ctx context.Context, ids []string) ([]Pr
 				Name:        p.Name,
 				Description: p.Description,
 				Price:       p.Price,
+				TaxClass:    taxClass(p.TaxClass),
+				Weight:      p.Weight,
 			})
 		}
 	}
@@ -162,7 +194,7 @@ func (r *elasticRepository) Search(ctx context.Context, query string, skip uint6
 		Size(int(take)).
 		Do(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "elasticsearch query failed", "error", err)
 		return nil, err
 	}
 
@@ -175,8 +207,18 @@ func (r *elasticRepository) Search(ctx context.Context, query string, skip uint6
 				Name:        p.Name,
 				Description: p.Description,
 				Price:       p.Price,
+				TaxClass:    taxClass(p.TaxClass),
+				Weight:      p.Weight,
 			})
 		}
 	}
 	return products, nil
 }
+
+// taxClass defaults the class of products indexed before tax classes existed.
+func taxClass(c string) string {
+	if c == "" {
+		return DefaultTaxClass
+	}
+	return c
+}
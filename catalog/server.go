@@ -3,47 +3,50 @@ package catalog
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"net"
 
 	"github.com/lichb0rn/go-microservices/catalog/pb"
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/grpcserver"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
 )
 
+// errorCodes maps the catalog errors to the codes they are sent with.
+var errorCodes = grpcerr.Codes{
+	ErrNotFound:       codes.NotFound,
+	ErrInvalidProduct: codes.InvalidArgument,
+}
+
 type grpcServer struct {
 	pb.UnimplementedCatalogServiceServer
 	service Service
 }
 
-func ListendGRPC(s Service, port int) error {
-	lis, err := net.Listen("tcp", ":"+fmt.Sprint(port))
-	if err != nil {
-		return err
-	}
-	server := grpc.NewServer()
+func ListendGRPC(s Service, checker *health.Checker, chain interceptor.Chain, port int) (*grpcserver.Server, error) {
+	server := grpc.NewServer(append(chain.ServerOptions(), grpcserver.KeepaliveEnforcement())...)
 	pb.RegisterCatalogServiceServer(server, &grpcServer{service: s})
+	checker.Register(server)
 	reflection.Register(server)
-	return server.Serve(lis)
+	return grpcserver.New(server, checker, port)
 }
 
 func (s *grpcServer) PostProduct(ctx context.Context, r *pb.PostProductRequest) (*pb.PostProductResponse, error) {
-	p, err := s.service.Put(ctx, r.Name, r.Description, r.Price)
+	p, err := s.service.Put(ctx, r.Name, r.Description, r.Price, r.TaxClass, r.Weight)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "product not created")
 	}
-	return &pb.PostProductResponse{Product: &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price}}, nil
+	return &pb.PostProductResponse{Product: &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, TaxClass: p.TaxClass, Weight: p.Weight}}, nil
 }
 
 func (s *grpcServer) GetProduct(ctx context.Context, r *pb.GetProductRequest) (*pb.GetProductResponse, error) {
 	p, err := s.service.GetOne(ctx, r.Id)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "product not found")
 	}
-	return &pb.GetProductResponse{Product: &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price}}, nil
+	return &pb.GetProductResponse{Product: &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, TaxClass: p.TaxClass, Weight: p.Weight}}, nil
 }
 
 func (s *grpcServer) GetProducts(ctx context.Context, r *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
@@ -58,13 +61,12 @@ func (s *grpcServer) GetProducts(ctx context.Context, r *pb.GetProductsRequest)
 	}
 
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		return nil, errorCodes.Status(ctx, err, "products not found")
 	}
 
 	products := make([]*pb.Product, 0, len(res))
 	for _, p := range res {
-		products = append(products, &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price})
+		products = append(products, &pb.Product{Id: p.ID, Name: p.Name, Description: p.Description, Price: p.Price, TaxClass: p.TaxClass, Weight: p.Weight})
 	}
-	return &pb.GetProductsResponse{Products: products}, err
+	return &pb.GetProductsResponse{Products: products}, nil
 }
@@ -2,12 +2,19 @@ package catalog
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
 
+	"github.com/lichb0rn/go-microservices/events"
+	eventpb "github.com/lichb0rn/go-microservices/events/pb"
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/metrics"
 	"github.com/segmentio/ksuid"
 )
 
 type Service interface {
-	Put(ctx context.Context, name, description string, price float64) (*Product, error)
+	Put(ctx context.Context, name, description string, price float64, taxClass string, weight float64) (*Product, error)
 	GetOne(ctx context.Context, id string) (*Product, error)
 	GetMany(ctx context.Context, skip uint64, take uint64) ([]Product, error)
 	GetManyByIDs(ctx context.Context, ids []string) ([]Product, error)
@@ -19,31 +26,100 @@ type Product struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	TaxClass    string  `json:"taxClass"`
+	// Weight is the shipping weight in kilograms.
+	Weight float64 `json:"weight"`
 }
 
+var (
+	ErrInvalidProduct = errors.New("invalid product")
+)
+
+var productsCreated = metrics.NewCounter("products_created_total", "Products created.")
+
+// DefaultTaxClass is the tax class of products created without one.
+const DefaultTaxClass = "standard"
+
 type catalogService struct {
 	reposiotry Repository
+	bus        events.EventBus
 }
 
-func NewService(r Repository) Service {
-	return &catalogService{r}
+type ServiceOption func(*catalogService)
+
+// WithEventBus publishes a ProductCreated event on the bus for every new
+// product. Products live in Elasticsearch, which has no transactions to
+// hold an outbox, so an event lost to a failing bus is only logged.
+func WithEventBus(bus events.EventBus) ServiceOption {
+	return func(s *catalogService) {
+		s.bus = bus
+	}
 }
 
-func (s *catalogService) Put(ctx context.Context, name, description string, price float64) (*Product, error) {
+func NewService(r Repository, opts ...ServiceOption) Service {
+	s := &catalogService{reposiotry: r}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *catalogService) Put(ctx context.Context, name, description string, price float64, taxClass string, weight float64) (*Product, error) {
+	invalid := &grpcerr.ValidationError{Err: ErrInvalidProduct}
+	if strings.TrimSpace(name) == "" {
+		invalid.Add("name", "must not be empty")
+	}
+	if price < 0 {
+		invalid.Add("price", "must not be negative")
+	}
+	if weight < 0 {
+		invalid.Add("weight", "must not be negative")
+	}
+	if err := invalid.OrNil(); err != nil {
+		return nil, err
+	}
+
+	if taxClass == "" {
+		taxClass = DefaultTaxClass
+	}
 	p := &Product{
 		Name:        name,
 		Description: description,
 		Price:       price,
+		TaxClass:    taxClass,
+		Weight:      weight,
 		ID:          ksuid.New().String(),
 	}
 
 	if err := s.reposiotry.Put(ctx, *p); err != nil {
 		return nil, err
 	}
+	productsCreated.Inc()
+
+	if s.bus != nil {
+		if err := s.publishCreated(ctx, *p); err != nil {
+			slog.ErrorContext(ctx, "ProductCreated not published", "product", p.ID, "error", err)
+		}
+	}
 
 	return p, nil
 }
 
+func (s *catalogService) publishCreated(ctx context.Context, p Product) error {
+	created, err := events.New(events.TypeProductCreated, events.ProductCreatedVersion, p.ID, &eventpb.ProductCreated{
+		ProductId:   p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		TaxClass:    p.TaxClass,
+		Weight:      p.Weight,
+	})
+	if err != nil {
+		return err
+	}
+	return s.bus.Publish(ctx, created)
+}
+
 func (s *catalogService) GetOne(ctx context.Context, id string) (*Product, error) {
 	return s.reposiotry.GetById(ctx, id)
 }
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/lichb0rn/go-microservices/events/pb"
+)
+
+type subscription struct {
+	handler Handler
+	types   map[string]bool
+}
+
+func (s *subscription) wants(eventType string) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
+
+// InProcessBus delivers events to the subscribers of the same process,
+// synchronously, in publishing order.
+type InProcessBus struct {
+	mu   sync.RWMutex
+	subs map[*subscription]struct{}
+}
+
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{subs: map[*subscription]struct{}{}}
+}
+
+// Publish hands the event to every interested subscriber. A failing
+// handler is logged and does not keep the others from the event.
+func (b *InProcessBus) Publish(ctx context.Context, e *pb.Envelope) error {
+	b.mu.RLock()
+	subs := make([]*subscription, 0, len(b.subs))
+	for s := range b.subs {
+		if s.wants(e.Type) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if err := s.handler(ctx, e); err != nil {
+			slog.ErrorContext(ctx, "event not handled", "type", e.Type, "id", e.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+func (b *InProcessBus) Subscribe(ctx context.Context, handler Handler, types ...string) error {
+	s := &subscription{handler: handler, types: map[string]bool{}}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, s)
+		b.mu.Unlock()
+	}()
+	return nil
+}
+
+func (b *InProcessBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs = map[*subscription]struct{}{}
+	return nil
+}
+
+// Open returns a PostgresBus on the channel of the database at url, or an
+// InProcessBus when url is empty.
+func Open(url, channel string) (EventBus, error) {
+	if url == "" {
+		return NewInProcessBus(), nil
+	}
+	return NewPostgresBus(url, channel)
+}
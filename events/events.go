@@ -0,0 +1,82 @@
+//go:generate protoc --go_out=./ events.proto
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/events/pb"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown event version")
+	ErrTypeMismatch   = errors.New("event type mismatch")
+)
+
+// Event types along with the current version of their payload.
+const (
+	TypeAccountCreated = "account.created"
+	TypeProductCreated = "product.created"
+	TypeOrderPlaced    = "order.placed"
+
+	AccountCreatedVersion = 1
+	ProductCreatedVersion = 1
+	OrderPlacedVersion    = 1
+)
+
+// Handler processes an event delivered by an EventBus.
+type Handler func(ctx context.Context, e *pb.Envelope) error
+
+// EventBus carries events between publishers and subscribers. Delivery is
+// at least once: a relayed event may be published again after a failure,
+// so handlers must tolerate duplicates, using the envelope id.
+type EventBus interface {
+	Publish(ctx context.Context, e *pb.Envelope) error
+	// Subscribe calls handler for the events of the given types, or of
+	// every type when none is given, published until ctx is done.
+	Subscribe(ctx context.Context, handler Handler, types ...string) error
+	Close() error
+}
+
+// New wraps the payload into an envelope with a new id.
+func New(eventType string, version uint32, aggregateId string, payload proto.Message) (*pb.Envelope, error) {
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	occurredAt, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Envelope{
+		Id:          ksuid.New().String(),
+		Type:        eventType,
+		Version:     version,
+		AggregateId: aggregateId,
+		OccurredAt:  occurredAt,
+		Payload:     data,
+	}, nil
+}
+
+// Decode unmarshals the payload of an envelope of the given type and
+// version into payload.
+func Decode(e *pb.Envelope, eventType string, version uint32, payload proto.Message) error {
+	if e.Type != eventType {
+		return ErrTypeMismatch
+	}
+	if e.Version != version {
+		return ErrUnknownVersion
+	}
+	return proto.Unmarshal(e.Payload, payload)
+}
+
+// OccurredAt returns when the event of the envelope happened.
+func OccurredAt(e *pb.Envelope) time.Time {
+	var t time.Time
+	t.UnmarshalBinary(e.OccurredAt)
+	return t
+}
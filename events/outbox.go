@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/lichb0rn/go-microservices/events/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// relayBatch is how many events the relay publishes per transaction.
+const relayBatch = 100
+
+// WriteOutbox stores the event in the outbox table of the transaction's
+// database, so that it is published if and only if tx commits.
+func WriteOutbox(ctx context.Context, tx *sql.Tx, e *pb.Envelope) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (event_id, event_type, envelope, created_at) VALUES ($1, $2, $3, $4)",
+		e.Id,
+		e.Type,
+		data,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+// Relay publishes the events of an outbox table through a bus, in the
+// order they were written. An event is marked published once the bus has
+// taken it, so a crash in between publishes it again.
+//
+// An event the bus can never carry, such as one over the NOTIFY size
+// limit, is dead-lettered: marked failed with the error and skipped from
+// then on, so that it does not hold the events after it back. It stays in
+// the table for inspection.
+type Relay struct {
+	db  *sql.DB
+	bus EventBus
+}
+
+func NewPostgresRelay(url string, bus EventBus) (*Relay, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Relay{db: db, bus: bus}, nil
+}
+
+func (r *Relay) Close() {
+	r.db.Close()
+}
+
+// Run flushes the outbox every interval until the context is done.
+func (r *Relay) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox not relayed", "error", err)
+			}
+			if err != nil || n < relayBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes a batch of pending events and returns how many were
+// published or dead-lettered. Concurrent relays skip the rows locked by
+// each other. When the bus fails, the events handled before it are still
+// marked.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// A no-op once committed.
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, envelope
+		FROM outbox
+		WHERE published_at IS NULL AND failed_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		relayBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int64{}
+	envelopes := []*pb.Envelope{}
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		e := &pb.Envelope{}
+		if err := proto.Unmarshal(data, e); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		envelopes = append(envelopes, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := []int64{}
+	handled := 0
+	var publishErr error
+	for i, e := range envelopes {
+		err := r.bus.Publish(ctx, e)
+		if errors.Is(err, ErrEventTooLarge) {
+			slog.ErrorContext(ctx, "event dead-lettered", "type", e.Type, "id", e.Id, "error", err)
+			if err := r.deadLetter(ctx, tx, ids[i], err); err != nil {
+				return 0, err
+			}
+			handled++
+			continue
+		}
+		if err != nil {
+			publishErr = err
+			break
+		}
+		published = append(published, ids[i])
+		handled++
+	}
+	if handled == 0 {
+		return 0, publishErr
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE outbox SET published_at = $1 WHERE id = ANY($2)",
+		time.Now().UTC(),
+		pq.Array(published),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return handled, publishErr
+}
+
+// deadLetter marks the event of the row as failed for good.
+func (r *Relay) deadLetter(ctx context.Context, tx *sql.Tx, id int64, cause error) error {
+	_, err := tx.ExecContext(ctx,
+		"UPDATE outbox SET failed_at = $1, error = $2 WHERE id = $3",
+		time.Now().UTC(),
+		cause.Error(),
+		id,
+	)
+	return err
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/lichb0rn/go-microservices/events/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts in a NOTIFY.
+const maxNotifyPayload = 8000
+
+var (
+	ErrEventTooLarge = errors.New("event too large to notify")
+)
+
+// PostgresBus carries events over LISTEN/NOTIFY on a channel of a Postgres
+// database, so that every process listening to it receives them.
+//
+// Notifications sent while a listener is disconnected are lost to it. The
+// outbox guarantees that events get published, not that every consumer
+// sees them: consumers that cannot miss one should reconcile on start.
+type PostgresBus struct {
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+	local    *InProcessBus
+}
+
+func NewPostgresBus(url, channel string) (*PostgresBus, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	listener := pq.NewListener(url, 10*time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("event listener", "error", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		db.Close()
+		return nil, err
+	}
+
+	b := &PostgresBus{
+		db:       db,
+		listener: listener,
+		channel:  channel,
+		local:    NewInProcessBus(),
+	}
+	go b.dispatch()
+	return b, nil
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, e *pb.Envelope) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(data)
+	if len(payload) > maxNotifyPayload {
+		return ErrEventTooLarge
+	}
+
+	_, err = b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, payload)
+	return err
+}
+
+func (b *PostgresBus) Subscribe(ctx context.Context, handler Handler, types ...string) error {
+	return b.local.Subscribe(ctx, handler, types...)
+}
+
+// dispatch hands the notifications to the local subscribers until the
+// listener is closed.
+func (b *PostgresBus) dispatch() {
+	for n := range b.listener.Notify {
+		if n == nil {
+			// Reconnected, notifications in between are lost.
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(n.Extra)
+		if err != nil {
+			slog.Warn("event not decoded", "error", err)
+			continue
+		}
+		e := &pb.Envelope{}
+		if err := proto.Unmarshal(data, e); err != nil {
+			slog.Warn("event not decoded", "error", err)
+			continue
+		}
+		b.local.Publish(context.Background(), e)
+	}
+}
+
+func (b *PostgresBus) Close() error {
+	err := b.listener.Close()
+	b.local.Close()
+	if dbErr := b.db.Close(); err == nil {
+		err = dbErr
+	}
+	return err
+}
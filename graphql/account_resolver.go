@@ -2,42 +2,58 @@ package main
 
 import (
 	"context"
-	"log"
+	"strings"
 	"time"
+
+	"github.com/lichb0rn/go-microservices/order"
 )
 
 type accountResolver struct {
 	server *Server
 }
 
-func (r *accountResolver) Orders(ctx context.Context, obj *Account) ([]*Order, error) {
+// Orders pages through the account's orders. The cursor is the id of the
+// last order of the previous page, passed back as after.
+func (r *accountResolver) Orders(ctx context.Context, obj *Account, filter *OrderFilterInput, sort *SortDirection, first *int, after *string) ([]*Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	orderList, err := r.server.orderClient.GetByAccountId(ctx, obj.ID)
+	f := order.OrderFilter{}
+	if filter != nil {
+		if filter.CreatedAfter != nil {
+			f.CreatedAfter = *filter.CreatedAfter
+		}
+		if filter.CreatedBefore != nil {
+			f.CreatedBefore = *filter.CreatedBefore
+		}
+		for _, s := range filter.Statuses {
+			f.Statuses = append(f.Statuses, order.Status(strings.ToLower(string(s))))
+		}
+		f.MinTotal = filter.MinTotal
+		f.MaxTotal = filter.MaxTotal
+	}
+	if sort != nil && *sort == SortDirectionDesc {
+		f.Descending = true
+	}
+	if first != nil {
+		if *first <= 0 {
+			return nil, ErrInvalidaParameter
+		}
+		f.Take = uint64(*first)
+	}
+	if after != nil {
+		f.After = *after
+	}
+
+	page, err := r.server.orderClient.GetByAccountId(ctx, obj.ID, f)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 
 	var orders []*Order
-	for _, o := range orderList {
-		var products []*OrderedProduct
-		for _, p := range o.Products {
-			products = append(products, &OrderedProduct{
-				ID:          p.ID,
-				Price:       p.Price,
-				Name:        p.Name,
-				Description: p.Description,
-				Quantity:    p.Quantity,
-			})
-		}
-		orders = append(orders, &Order{
-			ID:         o.ID,
-			CreatedAt:  o.CreatedAt,
-			TotalPrice: o.TotalPrice,
-			Products:   products,
-		})
+	for _, o := range page.Orders {
+		orders = append(orders, newOrder(&o))
 	}
 	return orders, nil
 }
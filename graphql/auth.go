@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthorized = errors.New("admin access required")
+)
+
+type adminCtxKey struct{}
+
+// withAdmin marks the requests bearing the admin token in the
+// Authorization header as made by an administrator. Without a token
+// configured, no request is.
+func withAdmin(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			r = r.WithContext(context.WithValue(r.Context(), adminCtxKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminCtxKey{}).(bool)
+	return admin
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/lichb0rn/go-microservices/cart"
+)
+
+func (o CartOwnerInput) owner() cart.Owner {
+	owner := cart.Owner{}
+	if o.AccountID != nil {
+		owner.AccountID = *o.AccountID
+	}
+	if o.SessionID != nil {
+		owner.SessionID = *o.SessionID
+	}
+	return owner
+}
+
+func newCart(c *cart.Cart) *Cart {
+	items := []*CartItem{}
+	for _, i := range c.Items {
+		items = append(items, &CartItem{
+			ProductID:   i.ProductID,
+			Name:        i.Name,
+			Description: i.Description,
+			Price:       i.Price,
+			Quantity:    i.Quantity,
+		})
+	}
+
+	gc := &Cart{
+		ID:         c.ID,
+		Items:      items,
+		TotalPrice: c.TotalPrice,
+		UpdatedAt:  c.UpdatedAt,
+	}
+	if c.AccountID != "" {
+		gc.AccountID = &c.AccountID
+	}
+	if c.SessionID != "" {
+		gc.SessionID = &c.SessionID
+	}
+	return gc
+}
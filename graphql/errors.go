@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes sent in the extensions of the GraphQL errors.
+const (
+	CodeNotFound        = "NOT_FOUND"
+	CodeBadUserInput    = "BAD_USER_INPUT"
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeUnavailable     = "UNAVAILABLE"
+	CodeInternal        = "INTERNAL"
+)
+
+const internalErrorMessage = "internal server error"
+
+// grpcErrorCodes maps the codes of the services to the codes of the API.
+// Any code missing is internal.
+var grpcErrorCodes = map[codes.Code]string{
+	codes.NotFound:           CodeNotFound,
+	codes.InvalidArgument:    CodeBadUserInput,
+	codes.FailedPrecondition: CodeBadUserInput,
+	codes.AlreadyExists:      CodeBadUserInput,
+	codes.OutOfRange:         CodeBadUserInput,
+	codes.Unauthenticated:    CodeUnauthenticated,
+	codes.PermissionDenied:   CodeForbidden,
+	codes.Unavailable:        CodeUnavailable,
+	codes.DeadlineExceeded:   CodeUnavailable,
+}
+
+// presentError sets extensions.code on every error sent to clients. The
+// errors of the services keep their message, unless they are internal,
+// and their field violations go to extensions.validation, listing the
+// problems of every field.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) {
+		// Raised by gqlgen itself: a value of the request it could not
+		// read, or a failure of the server such as a recovered panic.
+		if _, ok := gqlErr.Extensions["code"]; ok {
+			return gqlErr
+		}
+		if gqlErr.Err != nil {
+			return withCode(gqlErr, CodeBadUserInput)
+		}
+		return withCode(gqlErr, CodeInternal)
+	}
+
+	gqlErr = gqlerror.WrapPath(graphql.GetPath(ctx), err)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return withCode(gqlErr, CodeUnauthenticated)
+	case errors.Is(err, ErrInvalidaParameter):
+		return withCode(gqlErr, CodeBadUserInput)
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		gqlErr.Message = internalErrorMessage
+		return withCode(gqlErr, CodeInternal)
+	}
+	code, ok := grpcErrorCodes[st.Code()]
+	if !ok {
+		gqlErr.Message = internalErrorMessage
+		return withCode(gqlErr, CodeInternal)
+	}
+	if code == CodeUnavailable {
+		gqlErr.Message = "service unavailable, try again later"
+	} else {
+		gqlErr.Message = st.Message()
+	}
+
+	gqlErr = withCode(gqlErr, code)
+	if violations := grpcerr.FieldViolations(err); len(violations) > 0 {
+		validation := map[string][]string{}
+		for _, v := range violations {
+			validation[v.Field] = append(validation[v.Field], v.Description)
+		}
+		gqlErr.Extensions["validation"] = validation
+	}
+	return gqlErr
+}
+
+func withCode(err *gqlerror.Error, code string) *gqlerror.Error {
+	if err.Extensions == nil {
+		err.Extensions = map[string]interface{}{}
+	}
+	err.Extensions["code"] = code
+	return err
+}
@@ -1,34 +1,61 @@
 package main
 
 import (
+	"time"
+
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/lichb0rn/go-microservices/account"
+	"github.com/lichb0rn/go-microservices/cart"
 	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/grpcclient"
 	"github.com/lichb0rn/go-microservices/order"
+	"github.com/lichb0rn/go-microservices/payment"
 )
 
 type Server struct {
 	accountClient *account.Client
 	catalogClient *catalog.Client
 	orderClient   *order.Client
+	paymentClient *payment.Client
+	cartClient    *cart.Client
+	// checkoutTimeout bounds the mutations placing an order, which take
+	// longer than the other calls.
+	checkoutTimeout time.Duration
 }
 
-func NewGraphQLServer(accountUrl, catalogUrl, orderUrl string) (*Server, error) {
-	accountClient, err := account.NewClient(accountUrl)
+func NewGraphQLServer(accountUrl, catalogUrl, orderUrl, paymentUrl, cartUrl string, cfg grpcclient.Config, checkoutTimeout time.Duration) (*Server, error) {
+	accountClient, err := account.NewClient(accountUrl, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogClient, err := catalog.NewClient(catalogUrl, cfg)
 	if err != nil {
+		accountClient.Close()
 		return nil, err
 	}
 
-	catalogClient, err := catalog.NewClient(catalogUrl)
+	orderClient, err := order.NewClient(orderUrl, cfg)
 	if err != nil {
 		accountClient.Close()
+		catalogClient.Close()
 		return nil, err
 	}
 
-	orderClient, err := order.NewClient(orderUrl)
+	paymentClient, err := payment.NewClient(paymentUrl, cfg)
 	if err != nil {
 		accountClient.Close()
 		catalogClient.Close()
+		orderClient.Close()
+		return nil, err
+	}
+
+	cartClient, err := cart.NewClient(cartUrl, cfg)
+	if err != nil {
+		accountClient.Close()
+		catalogClient.Close()
+		orderClient.Close()
+		paymentClient.Close()
 		return nil, err
 	}
 
@@ -36,9 +63,21 @@ func NewGraphQLServer(accountUrl, catalogUrl, orderUrl string) (*Server, error)
 		accountClient,
 		catalogClient,
 		orderClient,
+		paymentClient,
+		cartClient,
+		checkoutTimeout,
 	}, nil
 }
 
+// Close closes the clients of the services.
+func (s *Server) Close() {
+	s.accountClient.Close()
+	s.catalogClient.Close()
+	s.orderClient.Close()
+	s.paymentClient.Close()
+	s.cartClient.Close()
+}
+
 func (s *Server) Mutation() MutationResolver {
 	return &mutationResolver{
 		server: s,
@@ -51,12 +90,24 @@ func (s *Server) Query() QueryResolver {
 	}
 }
 
+func (s *Server) Subscription() SubscriptionResolver {
+	return &subscriptionResolver{
+		server: s,
+	}
+}
+
 func (s *Server) Account() AccountResolver {
 	return &accountResolver{
 		server: s,
 	}
 }
 
+func (s *Server) Order() OrderResolver {
+	return &orderResolver{
+		server: s,
+	}
+}
+
 func (s *Server) ToExecutableSchema() graphql.ExecutableSchema {
 	return NewExecutableSchema(Config{
 		Resolvers: s,
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/lichb0rn/go-microservices/health"
+)
+
+// healthz tells the gateway is up, whatever its upstreams.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// readyz checks every upstream service and answers 503 unless they all
+// report themselves serving, listing the status of each.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	results := health.CheckAll(r.Context(), map[string]health.Check{
+		"account": s.accountClient.Health,
+		"catalog": s.catalogClient.Health,
+		"order":   s.orderClient.Health,
+		"payment": s.paymentClient.Health,
+		"cart":    s.cartClient.Health,
+	})
+
+	code := http.StatusOK
+	statuses := map[string]string{}
+	for _, res := range results {
+		if res.Err != nil {
+			code = http.StatusServiceUnavailable
+			statuses[res.Name] = res.Err.Error()
+			continue
+		}
+		statuses[res.Name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type idempotencyKeyCtxKey struct{}
+
+// withIdempotencyKey stores the Idempotency-Key request header in the context
+// so that mutations can forward it to the services.
+func withIdempotencyKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyKeyCtxKey{}, key))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// idempotencyKeyFrom prefers the explicit mutation argument over the header.
+func idempotencyKeyFrom(ctx context.Context, arg *string) string {
+	if arg != nil {
+		return *arg
+	}
+	key, _ := ctx.Value(idempotencyKeyCtxKey{}).(string)
+	return key
+}
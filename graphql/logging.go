@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// logError logs the failure of a resolver, with the path of its field.
+func logError(ctx context.Context, err error) {
+	attrs := []any{"error", err}
+	if fc := graphql.GetFieldContext(ctx); fc != nil {
+		attrs = append(attrs, "field", fc.Path().String())
+	}
+	slog.ErrorContext(ctx, "resolver failed", attrs...)
+}
+
+// operationLogger logs every operation, or every event of a subscription,
+// with its name, type, outcome and latency.
+type operationLogger struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = operationLogger{}
+
+func (operationLogger) ExtensionName() string {
+	return "OperationLogger"
+}
+
+func (operationLogger) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (operationLogger) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+
+	start := time.Now()
+	resp := next(ctx)
+
+	level := slog.LevelInfo
+	status := responseStatus(resp)
+	if status != "ok" {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "graphql",
+		"operation", operationName(oc),
+		"type", operationType(oc),
+		"status", status,
+		"latency", time.Since(start),
+	)
+	return resp
+}
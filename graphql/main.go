@@ -1,18 +1,47 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
 )
 
 type AppConfig struct {
 	AccountURL string `envconfig:"ACCOUNT_SERVICE_URL"`
 	CatalogURL string `envconfig:"CATALOG_SERVICE_URL"`
 	OrderURL   string `envconfig:"ORDER_SERVICE_URL"`
+	PaymentURL string `envconfig:"PAYMENT_SERVICE_URL"`
+	CartURL    string `envconfig:"CART_SERVICE_URL"`
+	// CheckoutTimeout bounds placing an order, directly or from a cart.
+	// The checkout saga gets the REQUEST_TIMEOUT of the order service
+	// (10s) for its steps and retries, so this leaves it time to answer.
+	CheckoutTimeout time.Duration `envconfig:"CHECKOUT_TIMEOUT" default:"12s"`
+	// AdminToken authorizes the admin-only operations, sent as a bearer token.
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
+	// ShutdownTimeout is how long the requests in flight get to finish on
+	// shutdown. Subscriptions are cut.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"8s"`
+	// TraceExporter is where spans go: stdout, memory or none.
+	TraceExporter string `envconfig:"TRACE_EXPORTER" default:"none"`
+	// LogFormat is json or text, and LogLevel debug, info, warn or error.
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+	// Client tunes the calls to the other services, from CLIENT_TIMEOUT,
+	// CLIENT_RETRY_ATTEMPTS, CLIENT_BREAKER_FAILURES and the like.
+	Client grpcclient.Config `envconfig:"CLIENT"`
 }
 
 func main() {
@@ -22,13 +51,54 @@ func main() {
 		log.Fatal(err)
 	}
 
-	s, err := NewGraphQLServer(cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL)
+	if err := logging.Setup("graphql", cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	exporter, err := tracing.NewExporter(cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracing.Configure("graphql", exporter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	clientCfg := cfg.Client.WithMethodTimeout(cfg.CheckoutTimeout, "PostOrder", "Checkout")
+	s, err := NewGraphQLServer(cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL, cfg.PaymentURL, cfg.CartURL, clientCfg, cfg.CheckoutTimeout)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer s.Close()
+
+	srv := handler.NewDefaultServer(s.ToExecutableSchema())
+	srv.SetErrorPresenter(presentError)
+	srv.Use(tracer{})
+	srv.Use(operationMetrics{})
+	srv.Use(operationLogger{})
 
-	http.Handle("/graphql", handler.NewDefaultServer(s.ToExecutableSchema()))
+	http.Handle("/graphql", logging.Middleware(tracing.Middleware(withAdmin(cfg.AdminToken, withIdempotencyKey(srv)))))
 	http.Handle("/playground", playground.Handler("gomicro", "/graphql"))
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/readyz", s.readyz)
+	http.Handle("/metrics", metrics.Handler())
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	server := &http.Server{Addr: ":8080"}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	slog.InfoContext(ctx, "shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.ErrorContext(ctx, "server not shut down", "error", err)
+	}
 }
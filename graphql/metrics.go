@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var (
+	operations = metrics.NewCounter("graphql_operations_total",
+		"GraphQL operations run, by top-level field, type and outcome.", "field", "type", "status")
+	operationDuration = metrics.NewHistogram("graphql_operation_duration_seconds",
+		"Time taken to run GraphQL operations, by top-level field.", nil, "field")
+)
+
+// operationMetrics counts and times every operation, or every event of a
+// subscription, once for each field it selects at the root. Operations are
+// labelled by those fields rather than by the names clients give them, so
+// that the series are bounded by the schema. An operation failing even
+// partly counts as an error.
+type operationMetrics struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = operationMetrics{}
+
+func (operationMetrics) ExtensionName() string {
+	return "OperationMetrics"
+}
+
+func (operationMetrics) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (operationMetrics) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+
+	start := time.Now()
+	resp := next(ctx)
+
+	elapsed := time.Since(start).Seconds()
+	for _, field := range rootFields(oc) {
+		operations.Inc(field, operationType(oc), responseStatus(resp))
+		operationDuration.Observe(elapsed, field)
+	}
+	return resp
+}
+
+// rootFields returns the names of the schema fields the operation selects
+// at its root, or other when there are none, such as when all of them come
+// from fragments.
+func rootFields(oc *graphql.OperationContext) []string {
+	fields := []string{}
+	if oc.Operation != nil {
+		seen := map[string]bool{}
+		for _, sel := range oc.Operation.SelectionSet {
+			f, ok := sel.(*ast.Field)
+			if !ok || f.Definition == nil || seen[f.Name] {
+				continue
+			}
+			seen[f.Name] = true
+			fields = append(fields, f.Name)
+		}
+	}
+	if len(fields) == 0 {
+		return []string{"other"}
+	}
+	return fields
+}
+
+// operationType returns query, mutation or subscription.
+func operationType(oc *graphql.OperationContext) string {
+	if oc.Operation == nil {
+		return ""
+	}
+	return string(oc.Operation.Operation)
+}
+
+// responseStatus tells a response with any error from a successful one.
+func responseStatus(resp *graphql.Response) string {
+	if resp != nil && len(resp.Errors) > 0 {
+		return "error"
+	}
+	return "ok"
+}
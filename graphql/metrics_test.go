@@ -0,0 +1,37 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2"
+)
+
+func TestRootFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "named by the client", query: `query WhateverTheClientLikes { products { id } }`, want: []string{"products"}},
+		{name: "anonymous", query: `{ products { id } }`, want: []string{"products"}},
+		{name: "several fields", query: `{ products { id } accounts { id } }`, want: []string{"products", "accounts"}},
+		{name: "aliased", query: `{ a: products { id } b: products { id } }`, want: []string{"products"}},
+		{name: "fragment only", query: `{ ...Root } fragment Root on Query { products { id } }`, want: []string{"other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, errs := gqlparser.LoadQuery(parsedSchema, tt.query)
+			if errs != nil {
+				t.Fatal(errs)
+			}
+			oc := &graphql.OperationContext{Doc: doc, Operation: doc.Operations[0]}
+
+			if got := rootFields(oc); !slices.Equal(got, tt.want) {
+				t.Errorf("rootFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,28 @@
 package main
 
+import "time"
+
 type Account struct {
 	ID     string  `json:"id"`
 	Name   string  `json:"name"`
 	Orders []Order `json:"orders"`
 }
+
+type Order struct {
+	ID              string               `json:"id"`
+	CreatedAt       time.Time            `json:"createdAt"`
+	Subtotal        float64              `json:"subtotal"`
+	DiscountTotal   float64              `json:"discountTotal"`
+	TaxTotal        float64              `json:"taxTotal"`
+	TotalPrice      float64              `json:"totalPrice"`
+	AccountID       string               `json:"accountId"`
+	Status          OrderStatus          `json:"status"`
+	History         []*OrderStatusChange `json:"history"`
+	Products        []*OrderedProduct    `json:"products"`
+	Coupon          *string              `json:"coupon"`
+	Discounts       []*OrderDiscount     `json:"discounts"`
+	FreeShipping    bool                 `json:"freeShipping"`
+	ShippingAddress *Address             `json:"shippingAddress"`
+	ShippingMethod  *string              `json:"shippingMethod"`
+	ShippingCost    float64              `json:"shippingCost"`
+}
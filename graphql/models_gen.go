@@ -3,6 +3,9 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
@@ -10,19 +13,77 @@ type AccountInput struct {
 	Name string `json:"name"`
 }
 
+type Address struct {
+	Line1      string  `json:"line1"`
+	Line2      *string `json:"line2,omitempty"`
+	City       string  `json:"city"`
+	Region     *string `json:"region,omitempty"`
+	PostalCode string  `json:"postalCode"`
+	Country    string  `json:"country"`
+}
+
+type AddressInput struct {
+	Line1      string  `json:"line1"`
+	Line2      *string `json:"line2,omitempty"`
+	City       string  `json:"city"`
+	Region     *string `json:"region,omitempty"`
+	PostalCode string  `json:"postalCode"`
+	Country    string  `json:"country"`
+}
+
+type Cart struct {
+	ID         string      `json:"id"`
+	AccountID  *string     `json:"accountId,omitempty"`
+	SessionID  *string     `json:"sessionId,omitempty"`
+	Items      []*CartItem `json:"items"`
+	TotalPrice float64     `json:"totalPrice"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
+
+type CartItem struct {
+	ProductID   string  `json:"productId"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"`
+}
+
+type CartOwnerInput struct {
+	AccountID *string `json:"accountId,omitempty"`
+	SessionID *string `json:"sessionId,omitempty"`
+}
+
 type Mutation struct {
 }
 
-type Order struct {
-	ID         string            `json:"id"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	TotalPrice float64           `json:"totalPrice"`
-	Products   []*OrderedProduct `json:"products"`
+type OrderDiscount struct {
+	PromotionID string        `json:"promotionId"`
+	Code        *string       `json:"code,omitempty"`
+	Kind        PromotionKind `json:"kind"`
+	ProductID   *string       `json:"productId,omitempty"`
+	Amount      float64       `json:"amount"`
+}
+
+type OrderEvent struct {
+	Type       OrderEventType `json:"type"`
+	Order      *Order         `json:"order"`
+	From       *OrderStatus   `json:"from,omitempty"`
+	OccurredAt time.Time      `json:"occurredAt"`
+}
+
+type OrderFilterInput struct {
+	CreatedAfter  *time.Time    `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time    `json:"createdBefore,omitempty"`
+	Statuses      []OrderStatus `json:"statuses,omitempty"`
+	MinTotal      *float64      `json:"minTotal,omitempty"`
+	MaxTotal      *float64      `json:"maxTotal,omitempty"`
 }
 
 type OrderInput struct {
-	AccountID string               `json:"accountId"`
-	Products  []*OrderProductInput `json:"products"`
+	AccountID       string               `json:"accountId"`
+	Products        []*OrderProductInput `json:"products"`
+	ShippingAddress *AddressInput        `json:"shippingAddress,omitempty"`
+	ShippingMethod  *string              `json:"shippingMethod,omitempty"`
 }
 
 type OrderProductInput struct {
@@ -30,12 +91,24 @@ type OrderProductInput struct {
 	Quantity int    `json:"quantity"`
 }
 
+type OrderStatusChange struct {
+	From      *OrderStatus `json:"from,omitempty"`
+	To        OrderStatus  `json:"to"`
+	Actor     string       `json:"actor"`
+	Reason    string       `json:"reason"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
 type OrderedProduct struct {
 	ID          string  `json:"id"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	Quantity    int     `json:"quantity"`
+	Discount    float64 `json:"discount"`
+	TaxClass    string  `json:"taxClass"`
+	TaxRate     float64 `json:"taxRate"`
+	Tax         float64 `json:"tax"`
 }
 
 type PaginationInput struct {
@@ -43,18 +116,493 @@ type PaginationInput struct {
 	Take *int `json:"take,omitempty"`
 }
 
+type Payment struct {
+	ID             string        `json:"id"`
+	Status         PaymentStatus `json:"status"`
+	Amount         float64       `json:"amount"`
+	CapturedAmount float64       `json:"capturedAmount"`
+	RefundedAmount float64       `json:"refundedAmount"`
+	Currency       string        `json:"currency"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
 type Product struct {
 	ID          string  `json:"id"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	TaxClass    string  `json:"taxClass"`
+	Weight      float64 `json:"weight"`
 }
 
 type ProductInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	TaxClass    *string  `json:"taxClass,omitempty"`
+	Weight      *float64 `json:"weight,omitempty"`
+}
+
+type ProductSales struct {
+	ProductID string  `json:"productId"`
+	Name      *string `json:"name,omitempty"`
+	Units     int     `json:"units"`
+	Revenue   float64 `json:"revenue"`
 }
 
 type Query struct {
 }
+
+type ReturnLine struct {
+	ProductID string  `json:"productId"`
+	Quantity  int     `json:"quantity"`
+	Amount    float64 `json:"amount"`
+}
+
+type ReturnLineInput struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type ReturnRequest struct {
+	ID           string        `json:"id"`
+	OrderID      string        `json:"orderId"`
+	AccountID    string        `json:"accountId"`
+	Status       ReturnStatus  `json:"status"`
+	Reason       string        `json:"reason"`
+	Note         *string       `json:"note,omitempty"`
+	Lines        []*ReturnLine `json:"lines"`
+	RefundAmount float64       `json:"refundAmount"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+type RevenuePoint struct {
+	Start   time.Time `json:"start"`
+	Orders  int       `json:"orders"`
+	Revenue float64   `json:"revenue"`
+}
+
+type SalesReport struct {
+	From                 time.Time       `json:"from"`
+	To                   time.Time       `json:"to"`
+	Period               ReportPeriod    `json:"period"`
+	Revenue              []*RevenuePoint `json:"revenue"`
+	TotalRevenue         float64         `json:"totalRevenue"`
+	Orders               int             `json:"orders"`
+	AverageOrderValue    float64         `json:"averageOrderValue"`
+	TopProductsByUnits   []*ProductSales `json:"topProductsByUnits"`
+	TopProductsByRevenue []*ProductSales `json:"topProductsByRevenue"`
+	NewCustomers         int             `json:"newCustomers"`
+	ReturningCustomers   int             `json:"returningCustomers"`
+}
+
+type Shipment struct {
+	ID             string           `json:"id"`
+	Carrier        string           `json:"carrier"`
+	TrackingNumber string           `json:"trackingNumber"`
+	Status         ShipmentStatus   `json:"status"`
+	Lines          []*ShipmentLine  `json:"lines"`
+	Events         []*TrackingEvent `json:"events"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+}
+
+type ShipmentLine struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type ShipmentLineInput struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type ShippingQuote struct {
+	MethodID string  `json:"methodId"`
+	Name     string  `json:"name"`
+	Cost     float64 `json:"cost"`
+}
+
+type Subscription struct {
+}
+
+type TrackingEvent struct {
+	Status      ShipmentStatus `json:"status"`
+	Description string         `json:"description"`
+	Location    string         `json:"location"`
+	OccurredAt  time.Time      `json:"occurredAt"`
+}
+
+type OrderEventType string
+
+const (
+	OrderEventTypeCreated       OrderEventType = "CREATED"
+	OrderEventTypeStatusChanged OrderEventType = "STATUS_CHANGED"
+)
+
+var AllOrderEventType = []OrderEventType{
+	OrderEventTypeCreated,
+	OrderEventTypeStatusChanged,
+}
+
+func (e OrderEventType) IsValid() bool {
+	switch e {
+	case OrderEventTypeCreated, OrderEventTypeStatusChanged:
+		return true
+	}
+	return false
+}
+
+func (e OrderEventType) String() string {
+	return string(e)
+}
+
+func (e *OrderEventType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderEventType", str)
+	}
+	return nil
+}
+
+func (e OrderEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusPending    OrderStatus = "PENDING"
+	OrderStatusPaid       OrderStatus = "PAID"
+	OrderStatusFulfilled  OrderStatus = "FULFILLED"
+	OrderStatusShipped    OrderStatus = "SHIPPED"
+	OrderStatusDelivered  OrderStatus = "DELIVERED"
+	OrderStatusCancelling OrderStatus = "CANCELLING"
+	OrderStatusCancelled  OrderStatus = "CANCELLED"
+	OrderStatusRefunded   OrderStatus = "REFUNDED"
+)
+
+var AllOrderStatus = []OrderStatus{
+	OrderStatusPending,
+	OrderStatusPaid,
+	OrderStatusFulfilled,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelling,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+}
+
+func (e OrderStatus) IsValid() bool {
+	switch e {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusFulfilled, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelling, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e OrderStatus) String() string {
+	return string(e)
+}
+
+func (e *OrderStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderStatus", str)
+	}
+	return nil
+}
+
+func (e OrderStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "PENDING"
+	PaymentStatusAuthorized        PaymentStatus = "AUTHORIZED"
+	PaymentStatusDeclined          PaymentStatus = "DECLINED"
+	PaymentStatusFailed            PaymentStatus = "FAILED"
+	PaymentStatusCaptured          PaymentStatus = "CAPTURED"
+	PaymentStatusPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
+	PaymentStatusRefunded          PaymentStatus = "REFUNDED"
+	PaymentStatusVoided            PaymentStatus = "VOIDED"
+)
+
+var AllPaymentStatus = []PaymentStatus{
+	PaymentStatusPending,
+	PaymentStatusAuthorized,
+	PaymentStatusDeclined,
+	PaymentStatusFailed,
+	PaymentStatusCaptured,
+	PaymentStatusPartiallyRefunded,
+	PaymentStatusRefunded,
+	PaymentStatusVoided,
+}
+
+func (e PaymentStatus) IsValid() bool {
+	switch e {
+	case PaymentStatusPending, PaymentStatusAuthorized, PaymentStatusDeclined, PaymentStatusFailed, PaymentStatusCaptured, PaymentStatusPartiallyRefunded, PaymentStatusRefunded, PaymentStatusVoided:
+		return true
+	}
+	return false
+}
+
+func (e PaymentStatus) String() string {
+	return string(e)
+}
+
+func (e *PaymentStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PaymentStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PaymentStatus", str)
+	}
+	return nil
+}
+
+func (e PaymentStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PromotionKind string
+
+const (
+	PromotionKindPercentage   PromotionKind = "PERCENTAGE"
+	PromotionKindFixedAmount  PromotionKind = "FIXED_AMOUNT"
+	PromotionKindBuyXGetY     PromotionKind = "BUY_X_GET_Y"
+	PromotionKindFreeShipping PromotionKind = "FREE_SHIPPING"
+)
+
+var AllPromotionKind = []PromotionKind{
+	PromotionKindPercentage,
+	PromotionKindFixedAmount,
+	PromotionKindBuyXGetY,
+	PromotionKindFreeShipping,
+}
+
+func (e PromotionKind) IsValid() bool {
+	switch e {
+	case PromotionKindPercentage, PromotionKindFixedAmount, PromotionKindBuyXGetY, PromotionKindFreeShipping:
+		return true
+	}
+	return false
+}
+
+func (e PromotionKind) String() string {
+	return string(e)
+}
+
+func (e *PromotionKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PromotionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PromotionKind", str)
+	}
+	return nil
+}
+
+func (e PromotionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportPeriod string
+
+const (
+	ReportPeriodDay   ReportPeriod = "DAY"
+	ReportPeriodWeek  ReportPeriod = "WEEK"
+	ReportPeriodMonth ReportPeriod = "MONTH"
+)
+
+var AllReportPeriod = []ReportPeriod{
+	ReportPeriodDay,
+	ReportPeriodWeek,
+	ReportPeriodMonth,
+}
+
+func (e ReportPeriod) IsValid() bool {
+	switch e {
+	case ReportPeriodDay, ReportPeriodWeek, ReportPeriodMonth:
+		return true
+	}
+	return false
+}
+
+func (e ReportPeriod) String() string {
+	return string(e)
+}
+
+func (e *ReportPeriod) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportPeriod(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportPeriod", str)
+	}
+	return nil
+}
+
+func (e ReportPeriod) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "REQUESTED"
+	ReturnStatusApproved  ReturnStatus = "APPROVED"
+	ReturnStatusRejected  ReturnStatus = "REJECTED"
+	ReturnStatusReceived  ReturnStatus = "RECEIVED"
+	ReturnStatusRefunding ReturnStatus = "REFUNDING"
+	ReturnStatusRefunded  ReturnStatus = "REFUNDED"
+)
+
+var AllReturnStatus = []ReturnStatus{
+	ReturnStatusRequested,
+	ReturnStatusApproved,
+	ReturnStatusRejected,
+	ReturnStatusReceived,
+	ReturnStatusRefunding,
+	ReturnStatusRefunded,
+}
+
+func (e ReturnStatus) IsValid() bool {
+	switch e {
+	case ReturnStatusRequested, ReturnStatusApproved, ReturnStatusRejected, ReturnStatusReceived, ReturnStatusRefunding, ReturnStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e ReturnStatus) String() string {
+	return string(e)
+}
+
+func (e *ReturnStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReturnStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReturnStatus", str)
+	}
+	return nil
+}
+
+func (e ReturnStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusCreated        ShipmentStatus = "CREATED"
+	ShipmentStatusInTransit      ShipmentStatus = "IN_TRANSIT"
+	ShipmentStatusOutForDelivery ShipmentStatus = "OUT_FOR_DELIVERY"
+	ShipmentStatusDelivered      ShipmentStatus = "DELIVERED"
+	ShipmentStatusException      ShipmentStatus = "EXCEPTION"
+)
+
+var AllShipmentStatus = []ShipmentStatus{
+	ShipmentStatusCreated,
+	ShipmentStatusInTransit,
+	ShipmentStatusOutForDelivery,
+	ShipmentStatusDelivered,
+	ShipmentStatusException,
+}
+
+func (e ShipmentStatus) IsValid() bool {
+	switch e {
+	case ShipmentStatusCreated, ShipmentStatusInTransit, ShipmentStatusOutForDelivery, ShipmentStatusDelivered, ShipmentStatusException:
+		return true
+	}
+	return false
+}
+
+func (e ShipmentStatus) String() string {
+	return string(e)
+}
+
+func (e *ShipmentStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ShipmentStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ShipmentStatus", str)
+	}
+	return nil
+}
+
+func (e ShipmentStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
 	"time"
 
 	"github.com/lichb0rn/go-microservices/order"
@@ -23,7 +22,7 @@ func (r *mutationResolver) CreateAccount(ctx context.Context, in AccountInput) (
 
 	acc, err := r.server.accountClient.Post(ctx, in.Name)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 	return &Account{ID: acc.ID, Name: acc.Name}, nil
@@ -33,9 +32,19 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput) (
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	product, err := r.server.catalogClient.PostProduct(ctx, in.Name, in.Description, in.Price)
+	taxClass := ""
+	if in.TaxClass != nil {
+		taxClass = *in.TaxClass
+	}
+
+	weight := 0.0
+	if in.Weight != nil {
+		weight = *in.Weight
+	}
+
+	product, err := r.server.catalogClient.PostProduct(ctx, in.Name, in.Description, in.Price, taxClass, weight)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 	return &Product{
@@ -43,11 +52,13 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput) (
 		Name:        product.Name,
 		Description: product.Description,
 		Price:       product.Price,
+		TaxClass:    product.TaxClass,
+		Weight:      product.Weight,
 	}, nil
 }
 
-func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Order, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput, idempotencyKey *string, applyCoupon *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.server.checkoutTimeout)
 	defer cancel()
 
 	var products []order.OrderedProduct
@@ -62,16 +73,199 @@ func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Ord
 		})
 	}
 
-	order, err := r.server.orderClient.Post(ctx, in.AccountID, products)
+	opts := order.PostOptions{IdempotencyKey: idempotencyKeyFrom(ctx, idempotencyKey)}
+	if applyCoupon != nil {
+		opts.Coupon = *applyCoupon
+	}
+	if in.ShippingAddress != nil {
+		opts.ShippingAddress = in.ShippingAddress.address()
+	}
+	if in.ShippingMethod != nil {
+		opts.ShippingMethod = *in.ShippingMethod
+	}
+
+	order, err := r.server.orderClient.Post(ctx, in.AccountID, products, opts)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 
-	return &Order{
-		ID:         order.ID,
-		CreatedAt:  order.CreatedAt,
-		TotalPrice: order.TotalPrice,
-	}, nil
+	return newOrder(order), nil
+
+}
+
+func (r *mutationResolver) CancelOrder(ctx context.Context, id string, accountID string, reason *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	why := ""
+	if reason != nil {
+		why = *reason
+	}
+
+	o, err := r.server.orderClient.Cancel(ctx, id, accountID, why)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newOrder(o), nil
+}
+
+// CreateShipment is restricted to administrators.
+func (r *mutationResolver) CreateShipment(ctx context.Context, orderID string, carrier string, lines []*ShipmentLineInput) (*Shipment, error) {
+	if !isAdmin(ctx) {
+		return nil, ErrUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	shipmentLines := make([]order.ShipmentLine, 0, len(lines))
+	for _, l := range lines {
+		shipmentLines = append(shipmentLines, order.ShipmentLine{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+
+	s, err := r.server.orderClient.CreateShipment(ctx, orderID, carrier, shipmentLines)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newShipment(*s), nil
+}
+
+func (r *mutationResolver) RequestReturn(ctx context.Context, orderID string, accountID string, lines []*ReturnLineInput, reason *string) (*ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	returnLines := make([]order.ReturnLine, 0, len(lines))
+	for _, l := range lines {
+		returnLines = append(returnLines, order.ReturnLine{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+	why := ""
+	if reason != nil {
+		why = *reason
+	}
+
+	ret, err := r.server.orderClient.RequestReturn(ctx, orderID, accountID, why, returnLines)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newReturnRequest(*ret), nil
+}
+
+func (r *mutationResolver) ApproveReturn(ctx context.Context, id string, note *string) (*ReturnRequest, error) {
+	return r.reviewReturn(ctx, id, note, r.server.orderClient.ApproveReturn)
+}
+
+func (r *mutationResolver) RejectReturn(ctx context.Context, id string, note *string) (*ReturnRequest, error) {
+	return r.reviewReturn(ctx, id, note, r.server.orderClient.RejectReturn)
+}
+
+func (r *mutationResolver) ReceiveReturn(ctx context.Context, id string) (*ReturnRequest, error) {
+	return r.reviewReturn(ctx, id, nil, func(ctx context.Context, id, _ string) (*order.Return, error) {
+		return r.server.orderClient.ReceiveReturn(ctx, id)
+	})
+}
+
+func (r *mutationResolver) RefundReturn(ctx context.Context, id string) (*ReturnRequest, error) {
+	return r.reviewReturn(ctx, id, nil, func(ctx context.Context, id, _ string) (*order.Return, error) {
+		return r.server.orderClient.RefundReturn(ctx, id)
+	})
+}
 
+// reviewReturn runs one of the admin steps of a return, which are
+// restricted to administrators.
+func (r *mutationResolver) reviewReturn(ctx context.Context, id string, note *string, step func(ctx context.Context, id, note string) (*order.Return, error)) (*ReturnRequest, error) {
+	if !isAdmin(ctx) {
+		return nil, ErrUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	n := ""
+	if note != nil {
+		n = *note
+	}
+
+	ret, err := step(ctx, id, n)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newReturnRequest(*ret), nil
+}
+
+func (r *mutationResolver) AddCartItem(ctx context.Context, owner CartOwnerInput, productID string, quantity int) (*Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if quantity <= 0 {
+		return nil, ErrInvalidaParameter
+	}
+
+	c, err := r.server.cartClient.AddItem(ctx, owner.owner(), productID, quantity)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newCart(c), nil
+}
+
+func (r *mutationResolver) UpdateCartItemQuantity(ctx context.Context, owner CartOwnerInput, productID string, quantity int) (*Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if quantity < 0 {
+		return nil, ErrInvalidaParameter
+	}
+
+	c, err := r.server.cartClient.UpdateQuantity(ctx, owner.owner(), productID, quantity)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newCart(c), nil
+}
+
+func (r *mutationResolver) RemoveCartItem(ctx context.Context, owner CartOwnerInput, productID string) (*Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	c, err := r.server.cartClient.RemoveItem(ctx, owner.owner(), productID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newCart(c), nil
+}
+
+func (r *mutationResolver) CheckoutCart(ctx context.Context, owner CartOwnerInput, idempotencyKey *string, applyCoupon *string, shippingAddress *AddressInput, shippingMethod *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.server.checkoutTimeout)
+	defer cancel()
+
+	opts := order.PostOptions{IdempotencyKey: idempotencyKeyFrom(ctx, idempotencyKey)}
+	if applyCoupon != nil {
+		opts.Coupon = *applyCoupon
+	}
+	if shippingAddress != nil {
+		opts.ShippingAddress = shippingAddress.address()
+	}
+	if shippingMethod != nil {
+		opts.ShippingMethod = *shippingMethod
+	}
+
+	orderId, err := r.server.cartClient.Checkout(ctx, owner.owner(), opts)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	o, err := r.server.orderClient.GetOne(ctx, orderId)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newOrder(o), nil
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/order"
+	"github.com/lichb0rn/go-microservices/payment"
+)
+
+type orderResolver struct {
+	server *Server
+}
+
+func (r *orderResolver) Account(ctx context.Context, obj *Order) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	acc, err := r.server.accountClient.GetOne(ctx, obj.AccountID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return &Account{ID: acc.ID, Name: acc.Name}, nil
+}
+
+// Payment returns the latest payment of the order, if any.
+func (r *orderResolver) Payment(ctx context.Context, obj *Order) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	payments, err := r.server.paymentClient.GetByOrderId(ctx, obj.ID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	if len(payments) == 0 {
+		return nil, nil
+	}
+	return newPayment(payments[len(payments)-1]), nil
+}
+
+func (r *orderResolver) Shipments(ctx context.Context, obj *Order) ([]*Shipment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	shipments, err := r.server.orderClient.GetShipments(ctx, obj.ID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	result := make([]*Shipment, 0, len(shipments))
+	for _, s := range shipments {
+		result = append(result, newShipment(s))
+	}
+	return result, nil
+}
+
+func (r *orderResolver) Returns(ctx context.Context, obj *Order) ([]*ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	returns, err := r.server.orderClient.GetReturns(ctx, obj.ID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	result := make([]*ReturnRequest, 0, len(returns))
+	for _, ret := range returns {
+		result = append(result, newReturnRequest(ret))
+	}
+	return result, nil
+}
+
+func newOrder(o *order.Order) *Order {
+	var products []*OrderedProduct
+	for _, p := range o.Products {
+		products = append(products, &OrderedProduct{
+			ID:          p.ID,
+			Price:       p.Price,
+			Name:        p.Name,
+			Description: p.Description,
+			Quantity:    p.Quantity,
+			Discount:    p.Discount,
+			TaxClass:    p.TaxClass,
+			TaxRate:     p.TaxRate,
+			Tax:         p.Tax,
+		})
+	}
+	discounts := []*OrderDiscount{}
+	for _, d := range o.Discounts {
+		discount := &OrderDiscount{
+			PromotionID: d.PromotionID,
+			Kind:        PromotionKind(strings.ToUpper(string(d.Kind))),
+			Amount:      d.Amount,
+		}
+		if d.Code != "" {
+			discount.Code = &d.Code
+		}
+		if d.ProductID != "" {
+			discount.ProductID = &d.ProductID
+		}
+		discounts = append(discounts, discount)
+	}
+	var history []*OrderStatusChange
+	for _, c := range o.History {
+		change := &OrderStatusChange{
+			To:        newOrderStatus(c.To),
+			Actor:     c.Actor,
+			Reason:    c.Reason,
+			CreatedAt: c.CreatedAt,
+		}
+		if c.From != "" {
+			from := newOrderStatus(c.From)
+			change.From = &from
+		}
+		history = append(history, change)
+	}
+	newOrder := &Order{
+		ID:            o.ID,
+		CreatedAt:     o.CreatedAt,
+		Subtotal:      o.Subtotal,
+		DiscountTotal: o.DiscountTotal,
+		TaxTotal:      o.TaxTotal,
+		TotalPrice:    o.TotalPrice,
+		AccountID:     o.AccountId,
+		Status:        newOrderStatus(o.Status),
+		History:       history,
+		Products:      products,
+		Discounts:     discounts,
+		FreeShipping:  o.FreeShipping,
+		ShippingCost:  o.ShippingCost,
+	}
+	if o.Coupon != "" {
+		newOrder.Coupon = &o.Coupon
+	}
+	if o.ShippingMethod != "" {
+		newOrder.ShippingMethod = &o.ShippingMethod
+	}
+	if o.ShippingAddress != (order.Address{}) {
+		newOrder.ShippingAddress = newAddress(o.ShippingAddress)
+	}
+	return newOrder
+}
+
+func newOrderStatus(s order.Status) OrderStatus {
+	return OrderStatus(strings.ToUpper(string(s)))
+}
+
+func newPayment(p payment.Payment) *Payment {
+	return &Payment{
+		ID:             p.ID,
+		Status:         PaymentStatus(strings.ToUpper(string(p.Status))),
+		Amount:         p.Amount,
+		CapturedAmount: p.CapturedAmount,
+		RefundedAmount: p.RefundedAmount,
+		Currency:       p.Currency,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+	}
+}
+
+func newAddress(a order.Address) *Address {
+	address := &Address{
+		Line1:      a.Line1,
+		City:       a.City,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+	if a.Line2 != "" {
+		address.Line2 = &a.Line2
+	}
+	if a.Region != "" {
+		address.Region = &a.Region
+	}
+	return address
+}
+
+func (in AddressInput) address() order.Address {
+	a := order.Address{
+		Line1:      in.Line1,
+		City:       in.City,
+		PostalCode: in.PostalCode,
+		Country:    in.Country,
+	}
+	if in.Line2 != nil {
+		a.Line2 = *in.Line2
+	}
+	if in.Region != nil {
+		a.Region = *in.Region
+	}
+	return a
+}
+
+func newShipment(s order.Shipment) *Shipment {
+	lines := make([]*ShipmentLine, 0, len(s.Lines))
+	for _, l := range s.Lines {
+		lines = append(lines, &ShipmentLine{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+	events := make([]*TrackingEvent, 0, len(s.Events))
+	for _, e := range s.Events {
+		events = append(events, &TrackingEvent{
+			Status:      newShipmentStatus(e.Status),
+			Description: e.Description,
+			Location:    e.Location,
+			OccurredAt:  e.OccurredAt,
+		})
+	}
+	return &Shipment{
+		ID:             s.ID,
+		Carrier:        s.Carrier,
+		TrackingNumber: s.TrackingNumber,
+		Status:         newShipmentStatus(s.Status),
+		Lines:          lines,
+		Events:         events,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+func newShipmentStatus(s order.ShipmentStatus) ShipmentStatus {
+	return ShipmentStatus(strings.ToUpper(string(s)))
+}
+
+func newReturnRequest(r order.Return) *ReturnRequest {
+	lines := make([]*ReturnLine, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		lines = append(lines, &ReturnLine{ProductID: l.ProductID, Quantity: l.Quantity, Amount: l.Amount})
+	}
+	ret := &ReturnRequest{
+		ID:           r.ID,
+		OrderID:      r.OrderID,
+		AccountID:    r.AccountID,
+		Status:       ReturnStatus(strings.ToUpper(string(r.Status))),
+		Reason:       r.Reason,
+		Lines:        lines,
+		RefundAmount: r.RefundAmount,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+	if r.Note != "" {
+		ret.Note = &r.Note
+	}
+	return ret
+}
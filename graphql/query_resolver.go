@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
-	"log"
+	"strings"
 	"time"
+
+	"github.com/lichb0rn/go-microservices/order"
 )
 
 type queryResolver struct {
@@ -17,7 +19,7 @@ func (r *queryResolver) Accounts(ctx context.Context, pagination *PaginationInpu
 	if id != nil {
 		r, err := r.server.accountClient.GetOne(ctx, *id)
 		if err != nil {
-			log.Println(err)
+			logError(ctx, err)
 			return nil, err
 		}
 		return []*Account{{
@@ -33,7 +35,7 @@ func (r *queryResolver) Accounts(ctx context.Context, pagination *PaginationInpu
 
 	accountsList, err := r.server.accountClient.GetMany(ctx, skip, take)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 
@@ -55,7 +57,7 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 	if id != nil {
 		r, err := r.server.catalogClient.GetProduct(ctx, *id)
 		if err != nil {
-			log.Println(err)
+			logError(ctx, err)
 			return nil, err
 		}
 		return []*Product{{
@@ -63,6 +65,8 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 			Name:        r.Name,
 			Description: r.Description,
 			Price:       r.Price,
+			TaxClass:    r.TaxClass,
+			Weight:      r.Weight,
 		}}, nil
 	}
 
@@ -77,7 +81,7 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 	}
 	productList, err := r.server.catalogClient.GetProducts(ctx, skip, take, nil, q)
 	if err != nil {
-		log.Println(err)
+		logError(ctx, err)
 		return nil, err
 	}
 
@@ -89,6 +93,8 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 				Name:        a.Name,
 				Description: a.Description,
 				Price:       a.Price,
+				TaxClass:    a.TaxClass,
+				Weight:      a.Weight,
 			},
 		)
 	}
@@ -97,6 +103,70 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 
 }
 
+func (r *queryResolver) Order(ctx context.Context, id string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	o, err := r.server.orderClient.GetOne(ctx, id)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newOrder(o), nil
+}
+
+func (r *queryResolver) Cart(ctx context.Context, owner CartOwnerInput) (*Cart, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	c, err := r.server.cartClient.GetCart(ctx, owner.owner())
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+	return newCart(c), nil
+}
+
+// GetShippingQuotes quotes the given products, or the content of the cart
+// when no products are given.
+func (r *queryResolver) GetShippingQuotes(ctx context.Context, address AddressInput, products []*OrderProductInput, cartOwner *CartOwnerInput) ([]*ShippingQuote, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var lines []order.OrderedProduct
+	for _, p := range products {
+		if p.Quantity <= 0 {
+			return nil, ErrInvalidaParameter
+		}
+		lines = append(lines, order.OrderedProduct{ID: p.ID, Quantity: p.Quantity})
+	}
+	if len(lines) == 0 && cartOwner != nil {
+		c, err := r.server.cartClient.GetCart(ctx, cartOwner.owner())
+		if err != nil {
+			logError(ctx, err)
+			return nil, err
+		}
+		for _, i := range c.Items {
+			lines = append(lines, order.OrderedProduct{ID: i.ProductID, Quantity: i.Quantity})
+		}
+	}
+	if len(lines) == 0 {
+		return []*ShippingQuote{}, nil
+	}
+
+	quotes, err := r.server.orderClient.GetShippingQuotes(ctx, lines, address.address())
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	res := make([]*ShippingQuote, 0, len(quotes))
+	for _, q := range quotes {
+		res = append(res, &ShippingQuote{MethodID: q.MethodID, Name: q.Name, Cost: q.Cost})
+	}
+	return res, nil
+}
+
 func (p PaginationInput) bounds() (uint64, uint64) {
 	skipValue := uint64(0)
 	takeValue := uint64(100)
@@ -108,3 +178,87 @@ func (p PaginationInput) bounds() (uint64, uint64) {
 	}
 	return skipValue, takeValue
 }
+
+// SalesReport is restricted to administrators.
+func (r *queryResolver) SalesReport(ctx context.Context, from time.Time, to time.Time, period ReportPeriod, top *int) (*SalesReport, error) {
+	if !isAdmin(ctx) {
+		return nil, ErrUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := order.ReportFilter{
+		From:   from,
+		To:     to,
+		Period: order.ReportPeriod(strings.ToLower(string(period))),
+	}
+	if top != nil {
+		filter.Top = *top
+	}
+
+	report, err := r.server.orderClient.GetSalesReport(ctx, filter)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	names, err := r.productNames(ctx, report)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	result := &SalesReport{
+		From:                 report.From,
+		To:                   report.To,
+		Period:               period,
+		Revenue:              make([]*RevenuePoint, 0, len(report.Revenue)),
+		TotalRevenue:         report.TotalRevenue,
+		Orders:               report.Orders,
+		AverageOrderValue:    report.AverageOrderValue,
+		TopProductsByUnits:   newProductSales(report.TopByUnits, names),
+		TopProductsByRevenue: newProductSales(report.TopByRevenue, names),
+		NewCustomers:         report.Customers.New,
+		ReturningCustomers:   report.Customers.Returning,
+	}
+	for _, p := range report.Revenue {
+		result.Revenue = append(result.Revenue, &RevenuePoint{Start: p.Start, Orders: p.Orders, Revenue: p.Revenue})
+	}
+	return result, nil
+}
+
+// productNames looks up the names of the ranked products in the catalog.
+func (r *queryResolver) productNames(ctx context.Context, report *order.SalesReport) (map[string]string, error) {
+	ids := []string{}
+	for _, ranking := range [][]order.ProductSales{report.TopByUnits, report.TopByRevenue} {
+		for _, p := range ranking {
+			ids = append(ids, p.ProductID)
+		}
+	}
+	names := map[string]string{}
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	products, err := r.server.catalogClient.GetProducts(ctx, 0, 0, ids, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		names[p.ID] = p.Name
+	}
+	return names, nil
+}
+
+func newProductSales(products []order.ProductSales, names map[string]string) []*ProductSales {
+	result := make([]*ProductSales, 0, len(products))
+	for _, p := range products {
+		sales := &ProductSales{ProductID: p.ProductID, Units: p.Units, Revenue: p.Revenue}
+		if name, ok := names[p.ProductID]; ok {
+			sales.Name = &name
+		}
+		result = append(result, sales)
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+type subscriptionResolver struct {
+	server *Server
+}
+
+// OrderUpdated streams the creations and status changes of the account's
+// orders. The subscription ends if the order service drops the stream,
+// in which case the client should refetch and subscribe again.
+func (r *subscriptionResolver) OrderUpdated(ctx context.Context, accountID string) (<-chan *OrderEvent, error) {
+	events, err := r.server.orderClient.WatchOrders(ctx, accountID)
+	if err != nil {
+		logError(ctx, err)
+		return nil, err
+	}
+
+	updates := make(chan *OrderEvent)
+	go func() {
+		defer close(updates)
+		for e := range events {
+			update := &OrderEvent{
+				Type:       OrderEventType(strings.ToUpper(string(e.Type))),
+				Order:      newOrder(&e.Order),
+				OccurredAt: e.OccurredAt,
+			}
+			if e.From != "" {
+				from := newOrderStatus(e.From)
+				update.From = &from
+			}
+
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
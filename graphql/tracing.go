@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/lichb0rn/go-microservices/tracing"
+)
+
+// tracer traces every GraphQL operation and the resolvers it runs, so
+// that the calls to the services show up under the field asking for them.
+type tracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = tracer{}
+
+func (tracer) ExtensionName() string {
+	return "Tracing"
+}
+
+func (tracer) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse traces an operation, or an event of a subscription.
+func (tracer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+
+	ctx, span := tracing.Start(ctx, "graphql "+operationName(oc), tracing.KindInternal)
+	if oc.Operation != nil {
+		span.SetAttribute("graphql.operation.type", string(oc.Operation.Operation))
+	}
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.End(errors.New(resp.Errors.Error()))
+	} else {
+		span.End(nil)
+	}
+	return resp
+}
+
+// InterceptField traces the fields having a resolver of their own, leaving
+// out the ones merely read from their parent.
+func (tracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := tracing.Start(ctx, "resolve "+fc.Object+"."+fc.Field.Name, tracing.KindInternal)
+	span.SetAttribute("graphql.path", fc.Path().String())
+	res, err := next(ctx)
+	span.End(err)
+	return res, err
+}
+
+func operationName(oc *graphql.OperationContext) string {
+	if oc.OperationName != "" {
+		return oc.OperationName
+	}
+	if oc.Operation != nil && oc.Operation.Name != "" {
+		return oc.Operation.Name
+	}
+	return "anonymous"
+}
@@ -0,0 +1,100 @@
+package grpcclient
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var breakerOpen = metrics.NewGauge("grpc_client_circuit_open",
+	"Whether the circuit breaker of an upstream is open, failing calls at once.", "upstream")
+
+// breaker stops calling an upstream that keeps failing. After failures
+// calls in a row fail, it opens and fails calls at once for cooldown, then
+// lets a single call through: the breaker closes if it succeeds and opens
+// again otherwise.
+type breaker struct {
+	upstream string
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	failed   int
+	openedAt time.Time
+	open     bool
+	// probing is set while the call let through after the cooldown runs.
+	probing bool
+}
+
+func newBreaker(upstream string, failures int, cooldown time.Duration) *breaker {
+	breakerOpen.Set(0, upstream)
+	return &breaker{upstream: upstream, failures: failures, cooldown: cooldown}
+}
+
+func (b *breaker) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if isHealthCheck(method) {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	if !b.allow() {
+		return status.Errorf(codes.Unavailable, "%s unavailable: circuit breaker open", b.upstream)
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	b.record(ctx, err)
+	return err
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !isFailure(err) {
+		if b.open {
+			slog.InfoContext(ctx, "circuit breaker closed", "upstream", b.upstream)
+			breakerOpen.Set(0, b.upstream)
+		}
+		b.open = false
+		b.failed = 0
+		return
+	}
+
+	b.failed++
+	if b.open || b.failed >= b.failures {
+		if !b.open {
+			slog.WarnContext(ctx, "circuit breaker opened", "upstream", b.upstream, "error", err)
+			breakerOpen.Set(1, b.upstream)
+		}
+		b.open = true
+		b.openedAt = time.Now()
+	}
+}
+
+// isFailure tells the errors of an upstream in trouble from the answers
+// of a working one, such as NotFound or InvalidArgument.
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInvoker answers calls with err, counting them. Its during hook runs
+// while a call is in flight, as a concurrent caller would.
+type fakeInvoker struct {
+	err    error
+	during func()
+	calls  int
+}
+
+func (f *fakeInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	f.calls++
+	if f.during != nil {
+		during := f.during
+		f.during = nil
+		during()
+	}
+	return f.err
+}
+
+const testMethod = "/test.Service/Call"
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	unavailable := status.Error(codes.Unavailable, "down")
+	notFound := status.Error(codes.NotFound, "no such thing")
+
+	type call struct {
+		// wait is how long to sleep before the call.
+		wait time.Duration
+		err  error
+		// wantInvoked tells whether the call reaches the upstream, rather
+		// than being failed at once by the breaker.
+		wantInvoked bool
+	}
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			name: "stays closed below the threshold",
+			calls: []call{
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: nil, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: nil, wantInvoked: true},
+			},
+		},
+		{
+			name: "answers of a working upstream are not failures",
+			calls: []call{
+				{err: notFound, wantInvoked: true},
+				{err: notFound, wantInvoked: true},
+				{err: notFound, wantInvoked: true},
+				{err: notFound, wantInvoked: true},
+			},
+		},
+		{
+			name: "opens after failures in a row",
+			calls: []call{
+				{err: unavailable, wantInvoked: true},
+				{err: status.Error(codes.DeadlineExceeded, "slow"), wantInvoked: true},
+				{err: status.Error(codes.Internal, "broken"), wantInvoked: true},
+				{err: nil},
+				{err: nil},
+			},
+		},
+		{
+			name: "closes when the probe succeeds",
+			calls: []call{
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: nil},
+				{wait: 2 * cooldown, err: nil, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: nil, wantInvoked: true},
+			},
+		},
+		{
+			name: "opens again when the probe fails",
+			calls: []call{
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{err: unavailable, wantInvoked: true},
+				{wait: 2 * cooldown, err: unavailable, wantInvoked: true},
+				{err: nil},
+				{wait: 2 * cooldown, err: nil, wantInvoked: true},
+				{err: nil, wantInvoked: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker("test", 3, cooldown)
+			for i, c := range tt.calls {
+				time.Sleep(c.wait)
+				invoker := &fakeInvoker{err: c.err}
+
+				err := b.intercept(context.Background(), testMethod, nil, nil, nil, invoker.invoke)
+				if invoked := invoker.calls > 0; invoked != c.wantInvoked {
+					t.Fatalf("call %d reached the upstream: %t, want %t", i, invoked, c.wantInvoked)
+				}
+				wantErr := c.err
+				if !c.wantInvoked {
+					wantErr = status.Error(codes.Unavailable, "test unavailable: circuit breaker open")
+				}
+				if status.Code(err) != status.Code(wantErr) || status.Convert(err).Message() != status.Convert(wantErr).Message() {
+					t.Errorf("call %d error = %v, want %v", i, err, wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	const cooldown = 10 * time.Millisecond
+	b := newBreaker("test", 1, cooldown)
+	failing := &fakeInvoker{err: status.Error(codes.Unavailable, "down")}
+	b.intercept(context.Background(), testMethod, nil, nil, nil, failing.invoke)
+	time.Sleep(2 * cooldown)
+
+	concurrent := &fakeInvoker{}
+	var concurrentErr error
+	probe := &fakeInvoker{during: func() {
+		concurrentErr = b.intercept(context.Background(), testMethod, nil, nil, nil, concurrent.invoke)
+	}}
+	if err := b.intercept(context.Background(), testMethod, nil, nil, nil, probe.invoke); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if concurrent.calls != 0 || status.Code(concurrentErr) != codes.Unavailable {
+		t.Errorf("call during the probe reached the upstream %d times with error %v, want it failed at once", concurrent.calls, concurrentErr)
+	}
+}
+
+func TestBreakerLetsHealthChecksThrough(t *testing.T) {
+	b := newBreaker("test", 1, time.Hour)
+	failing := &fakeInvoker{err: status.Error(codes.Unavailable, "down")}
+	b.intercept(context.Background(), testMethod, nil, nil, nil, failing.invoke)
+
+	check := &fakeInvoker{}
+	if err := b.intercept(context.Background(), "/grpc.health.v1.Health/Check", nil, nil, nil, check.invoke); err != nil || check.calls != 1 {
+		t.Errorf("health check reached the upstream %d times with error %v, want once", check.calls, err)
+	}
+	call := &fakeInvoker{}
+	if err := b.intercept(context.Background(), testMethod, nil, nil, nil, call.invoke); call.calls != 0 || status.Code(err) != codes.Unavailable {
+		t.Errorf("call reached the upstream %d times with error %v, want the breaker still open", call.calls, err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package grpcclient dials the services for their clients, with default
+// deadlines, retries of idempotent calls, a circuit breaker per upstream
+// and keepalive.
+package grpcclient
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Config tunes the calls of a client. Its zero value dials with the
+// defaults of gRPC: no deadline, retry, breaker or keepalive. Loaded with
+// envconfig, it defaults to the values of its tags.
+type Config struct {
+	// Timeout is the deadline of calls made without a sooner one.
+	// Streams are left alone.
+	Timeout time.Duration `envconfig:"TIMEOUT" default:"5s"`
+	// MethodTimeouts overrides Timeout for some methods, by name, such as
+	// "GetProducts:2s,PostOrder:15s".
+	MethodTimeouts map[string]time.Duration `envconfig:"METHOD_TIMEOUTS"`
+	// RetryAttempts is how many times idempotent calls are tried while
+	// the upstream is unavailable, the first one included.
+	RetryAttempts int `envconfig:"RETRY_ATTEMPTS" default:"3"`
+	// RetryBackoff is the wait before the first retry, doubled every
+	// retry up to RetryMaxBackoff.
+	RetryBackoff    time.Duration `envconfig:"RETRY_BACKOFF" default:"100ms"`
+	RetryMaxBackoff time.Duration `envconfig:"RETRY_MAX_BACKOFF" default:"1s"`
+	// BreakerFailures is how many calls in a row must fail for the breaker
+	// to open and fail the next ones at once, for BreakerCooldown.
+	BreakerFailures int           `envconfig:"BREAKER_FAILURES" default:"5"`
+	BreakerCooldown time.Duration `envconfig:"BREAKER_COOLDOWN" default:"10s"`
+	// KeepaliveTime is how long a connection stays idle before the client
+	// pings the server, and KeepaliveTimeout how long it waits for the
+	// answer before closing it. gRPC pings every 10s at most.
+	KeepaliveTime    time.Duration `envconfig:"KEEPALIVE_TIME" default:"30s"`
+	KeepaliveTimeout time.Duration `envconfig:"KEEPALIVE_TIMEOUT" default:"10s"`
+}
+
+// timeout returns the deadline of the method, given as /package.Service/Method.
+func (c Config) timeout(method string) time.Duration {
+	if t, ok := c.MethodTimeouts[method]; ok {
+		return t
+	}
+	if t, ok := c.MethodTimeouts[methodName(method)]; ok {
+		return t
+	}
+	return c.Timeout
+}
+
+// WithMethodTimeout returns a copy of c in which the methods without a
+// timeout of their own get timeout instead of Timeout.
+func (c Config) WithMethodTimeout(timeout time.Duration, methods ...string) Config {
+	timeouts := make(map[string]time.Duration, len(c.MethodTimeouts)+len(methods))
+	for m, t := range c.MethodTimeouts {
+		timeouts[m] = t
+	}
+	for _, m := range methods {
+		if _, ok := timeouts[m]; !ok {
+			timeouts[m] = timeout
+		}
+	}
+	c.MethodTimeouts = timeouts
+	return c
+}
+
+func methodName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
+
+// isHealthCheck tells the health checks of the upstream, which must see it
+// as it is rather than through the breaker.
+func isHealthCheck(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
+
+// Dial connects to the upstream at url. Only the methods named idempotent
+// are retried, as running them twice is harmless.
+func Dial(url, upstream string, cfg Config, idempotent ...string) (*grpc.ClientConn, error) {
+	retried := make(map[string]bool, len(idempotent))
+	for _, m := range idempotent {
+		retried[m] = true
+	}
+
+	unary := []grpc.UnaryClientInterceptor{
+		logging.UnaryClientInterceptor(),
+		tracing.UnaryClientInterceptor(),
+		deadline(cfg),
+	}
+	if cfg.BreakerFailures > 0 {
+		unary = append(unary, newBreaker(upstream, cfg.BreakerFailures, cfg.BreakerCooldown).intercept)
+	}
+	if cfg.RetryAttempts > 1 {
+		unary = append(unary, retry(cfg, retried))
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor(), tracing.StreamClientInterceptor()),
+	}
+	if cfg.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	return grpc.NewClient(url, opts...)
+}
@@ -0,0 +1,54 @@
+package grpcclient
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// deadline bounds every call to the timeout of its method, keeping the
+// deadline of the caller when it is sooner.
+func deadline(cfg Config) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout := cfg.timeout(method)
+		if d, ok := ctx.Deadline(); timeout > 0 && (!ok || time.Until(d) > timeout) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// retry tries the idempotent calls again, backing off, while the upstream
+// is unavailable and the deadline allows.
+func retry(cfg Config, idempotent map[string]bool) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !idempotent[methodName(method)] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := cfg.RetryBackoff
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= cfg.RetryAttempts || status.Code(err) != codes.Unavailable {
+				return err
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			backoff *= 2
+			if cfg.RetryMaxBackoff > 0 && backoff > cfg.RetryMaxBackoff {
+				backoff = cfg.RetryMaxBackoff
+			}
+		}
+	}
+}
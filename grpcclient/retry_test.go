@@ -0,0 +1,121 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// unavailableServer answers every call Unavailable, counting the calls by
+// method.
+type unavailableServer struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *unavailableServer) handle(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	s.mu.Lock()
+	s.calls[method]++
+	s.mu.Unlock()
+	return status.Error(codes.Unavailable, "down")
+}
+
+func (s *unavailableServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// dialUnavailable dials a server that is always unavailable, through
+// Dial like the service clients do, retrying the idempotent methods.
+func dialUnavailable(t *testing.T, cfg Config, idempotent ...string) (*grpc.ClientConn, *unavailableServer) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &unavailableServer{calls: map[string]int{}}
+	server := grpc.NewServer(grpc.UnknownServiceHandler(s.handle))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := Dial(lis.Addr().String(), "test", cfg, idempotent...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, s
+}
+
+func TestRetryOnlyIdempotentMethods(t *testing.T) {
+	cfg := Config{Timeout: 5 * time.Second, RetryAttempts: 3, RetryBackoff: time.Millisecond}
+	conn, server := dialUnavailable(t, cfg, "GetOrder", "GetPayment", "GetPaymentsForOrder")
+
+	tests := []struct {
+		method    string
+		wantCalls int
+	}{
+		{method: "/pb.OrderService/GetOrder", wantCalls: 3},
+		{method: "/pb.PaymentService/GetPayment", wantCalls: 3},
+		{method: "/pb.PaymentService/GetPaymentsForOrder", wantCalls: 3},
+		{method: "/pb.OrderService/PostOrder", wantCalls: 1},
+		{method: "/pb.OrderService/Checkout", wantCalls: 1},
+		{method: "/pb.PaymentService/Authorize", wantCalls: 1},
+		{method: "/pb.PaymentService/Capture", wantCalls: 1},
+		{method: "/pb.PaymentService/Refund", wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			err := conn.Invoke(context.Background(), tt.method, &durationpb.Duration{}, &durationpb.Duration{})
+			if status.Code(err) != codes.Unavailable {
+				t.Fatalf("Invoke() error = %v, want Unavailable", err)
+			}
+			if got := server.count(tt.method); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtTheDeadline(t *testing.T) {
+	cfg := Config{Timeout: 50 * time.Millisecond, RetryAttempts: 100, RetryBackoff: time.Second}
+	conn, server := dialUnavailable(t, cfg, "GetOrder")
+
+	start := time.Now()
+	err := conn.Invoke(context.Background(), "/pb.OrderService/GetOrder", &durationpb.Duration{}, &durationpb.Duration{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Invoke() error = %v, want the last Unavailable", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Invoke() took %s, want it cut at the deadline", elapsed)
+	}
+	if got := server.count("/pb.OrderService/GetOrder"); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestBreakerOpensThroughDial(t *testing.T) {
+	cfg := Config{Timeout: 5 * time.Second, BreakerFailures: 2, BreakerCooldown: time.Hour}
+	conn, server := dialUnavailable(t, cfg)
+
+	const method = "/pb.PaymentService/Authorize"
+	for i := 0; i < 4; i++ {
+		err := conn.Invoke(context.Background(), method, &durationpb.Duration{}, &durationpb.Duration{})
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d error = %v, want Unavailable", i, err)
+		}
+	}
+	if got := server.count(method); got != 2 {
+		t.Errorf("calls = %d, want 2 before the breaker opened", got)
+	}
+}
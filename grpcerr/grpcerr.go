@@ -0,0 +1,155 @@
+// Package grpcerr turns domain errors into gRPC statuses, so that clients
+// can tell a missing entity from an invalid request or a server failure.
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FieldViolation tells what is wrong with a field of a request.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError is a domain error along with the fields that caused it.
+// It is sent as InvalidArgument, or the code of Err, with the violations
+// in a BadRequest detail.
+type ValidationError struct {
+	Err        error
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		fields = append(fields, v.Field+": "+v.Description)
+	}
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(fields, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Invalid wraps err with a violation of the field.
+func Invalid(err error, field, description string) *ValidationError {
+	return &ValidationError{Err: err, Violations: []FieldViolation{{Field: field, Description: description}}}
+}
+
+// Add appends a violation of the field.
+func (e *ValidationError) Add(field, description string) *ValidationError {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Description: description})
+	return e
+}
+
+// OrNil returns the error if there is any violation, nil otherwise. It
+// lets a validation collect violations before deciding whether it failed.
+func (e *ValidationError) OrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Codes maps the domain errors of a service to the gRPC codes they are
+// sent with.
+type Codes map[error]codes.Code
+
+// Status returns the gRPC status error for err. An error wrapping one of
+// the mapped errors gets its code and message. Anything else is logged and
+// sent as Internal with the generic message, keeping internals from the
+// client.
+func (c Codes) Status(ctx context.Context, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		// Already a status, from a downstream service or the server itself.
+		return err
+	}
+
+	code, ok := c.code(err)
+	if !ok {
+		slog.ErrorContext(ctx, message, "error", err)
+		return status.Error(codes.Internal, message)
+	}
+
+	st := status.New(code, err.Error())
+	var v *ValidationError
+	if errors.As(err, &v) && len(v.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, fv := range v.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fv.Field,
+				Description: fv.Description,
+			})
+		}
+		if detailed, err := st.WithDetails(br); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// code finds the code of the outermost mapped error err wraps.
+func (c Codes) code(err error) (codes.Code, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if code, ok := c[e]; ok {
+			return code, true
+		}
+	}
+
+	// Joined errors are not walked by errors.Unwrap. Sorting the candidates
+	// keeps the outcome stable when several match.
+	matches := []codes.Code{}
+	for target, code := range c {
+		if errors.Is(err, target) {
+			matches = append(matches, code)
+		}
+	}
+	if len(matches) > 0 {
+		sort.Slice(matches, func(i, j int) bool { return matches[i] < matches[j] })
+		return matches[0], true
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded, true
+	}
+
+	var v *ValidationError
+	if errors.As(err, &v) {
+		return codes.InvalidArgument, true
+	}
+	return 0, false
+}
+
+// FieldViolations returns the field violations carried by a status error.
+func FieldViolations(err error) []FieldViolation {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	violations := []FieldViolation{}
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, fv := range br.FieldViolations {
+				violations = append(violations, FieldViolation{Field: fv.Field, Description: fv.Description})
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,95 @@
+// Package grpcserver runs the gRPC server of a service until it is told to
+// stop, then drains it.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// minPingInterval is how often clients may ping idle connections to keep
+// them alive. gRPC servers otherwise close the connections of clients
+// pinging more than every five minutes.
+const minPingInterval = 10 * time.Second
+
+// KeepaliveEnforcement lets the clients of the services ping as often as
+// gRPC allows, even between calls.
+func KeepaliveEnforcement() grpc.ServerOption {
+	return grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             minPingInterval,
+		PermitWithoutStream: true,
+	})
+}
+
+// Server is a gRPC server bound to its port.
+type Server struct {
+	server  *grpc.Server
+	lis     net.Listener
+	checker *health.Checker
+}
+
+// New listens on the port for server. The checker, if any, is told when
+// the server stops.
+func New(server *grpc.Server, checker *health.Checker, port int) (*Server, error) {
+	lis, err := net.Listen("tcp", ":"+fmt.Sprint(port))
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server: server, lis: lis, checker: checker}, nil
+}
+
+// Serve serves until the server is stopped.
+func (s *Server) Serve() error {
+	return s.server.Serve(s.lis)
+}
+
+// Run serves until ctx is done, then shuts the server down, giving the
+// calls in flight up to drain to finish.
+func (s *Server) Run(ctx context.Context, drain time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Serve()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.Shutdown(ctx, drain)
+	return <-errs
+}
+
+// Shutdown reports the service as not serving, stops accepting calls and
+// waits up to drain for the ones in flight, cancelling those still running
+// afterwards, such as long-lived streams.
+func (s *Server) Shutdown(ctx context.Context, drain time.Duration) {
+	if s.checker != nil {
+		s.checker.Shutdown()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		slog.WarnContext(ctx, "calls still running, stopping", "drain", drain)
+		s.server.Stop()
+		<-stopped
+	}
+}
@@ -0,0 +1,176 @@
+// Package health reports whether a service and the dependencies it needs
+// work, through the standard grpc.health.v1 service.
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	ErrNotServing = errors.New("not serving")
+)
+
+// checkTimeout bounds every check of a dependency.
+const checkTimeout = 2 * time.Second
+
+// Check tells whether a dependency works.
+type Check func(ctx context.Context) error
+
+// Checker runs the checks of the dependencies of a service. Every
+// dependency is reported under its own name, and the server under the
+// empty name and the names of its services: serving only while every
+// dependency is.
+type Checker struct {
+	checks map[string]Check
+	server *grpchealth.Server
+
+	mu       sync.Mutex
+	services []string
+	results  map[string]error
+}
+
+func NewChecker(checks map[string]Check) *Checker {
+	c := &Checker{
+		checks:   checks,
+		server:   grpchealth.NewServer(),
+		services: []string{""},
+		results:  map[string]error{},
+	}
+	// Not serving until the dependencies are checked once.
+	c.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for name := range checks {
+		c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return c
+}
+
+// Register serves the health of the services already registered on the
+// server.
+func (c *Checker) Register(s *grpc.Server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range s.GetServiceInfo() {
+		c.services = append(c.services, name)
+		c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	healthpb.RegisterHealthServer(s, c.server)
+}
+
+// Run checks the dependencies every given interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		c.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow checks every dependency at once and updates their status.
+func (c *Checker) CheckNow(ctx context.Context) {
+	results := runChecks(ctx, c.checks)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	serving := true
+	for name, err := range results {
+		if err != nil {
+			serving = false
+			if c.results[name] == nil {
+				slog.WarnContext(ctx, "dependency unhealthy", "dependency", name, "error", err)
+			}
+		}
+		c.server.SetServingStatus(name, servingStatus(err == nil))
+	}
+	c.results = results
+	for _, name := range c.services {
+		c.server.SetServingStatus(name, servingStatus(serving))
+	}
+}
+
+// Shutdown reports the service as not serving from now on, whatever its
+// dependencies, so that it gets no new calls while it stops.
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func servingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+	if serving {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Downstream checks a service reached through conn by asking its own
+// health service.
+func Downstream(conn grpc.ClientConnInterface) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		r, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if r.Status != healthpb.HealthCheckResponse_SERVING {
+			return ErrNotServing
+		}
+		return nil
+	}
+}
+
+// Result is the outcome of the check of a dependency.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// CheckAll runs the checks at once and returns their outcome sorted by
+// name.
+func CheckAll(ctx context.Context, checks map[string]Check) []Result {
+	return sortedResults(runChecks(ctx, checks))
+}
+
+func runChecks(ctx context.Context, checks map[string]Check) map[string]error {
+	results := map[string]error{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			err := check(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func sortedResults(results map[string]error) []Result {
+	sorted := make([]Result, 0, len(results))
+	for name, err := range results {
+		sorted = append(sorted, Result{Name: name, Err: err})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
@@ -0,0 +1,138 @@
+// Package interceptor holds the gRPC server interceptors shared by the
+// services: panic recovery, request logging and deadline enforcement.
+package interceptor
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Chain is the interceptors a server runs around every call, the first
+// one outermost.
+type Chain struct {
+	Unary  []grpc.UnaryServerInterceptor
+	Stream []grpc.StreamServerInterceptor
+}
+
+// Default gives every call a request ID, then traces, measures and logs
+// it, turns panics into Internal errors and bounds unary calls to timeout.
+// A timeout of zero leaves deadlines to clients.
+func Default(timeout time.Duration) Chain {
+	c := Chain{
+		Unary: []grpc.UnaryServerInterceptor{
+			logging.UnaryServerInterceptor(), tracing.UnaryServerInterceptor(), metrics.UnaryServerInterceptor(),
+			UnaryLogging(), UnaryRecovery(),
+		},
+		Stream: []grpc.StreamServerInterceptor{
+			logging.StreamServerInterceptor(), tracing.StreamServerInterceptor(), metrics.StreamServerInterceptor(),
+			StreamLogging(), StreamRecovery(),
+		},
+	}
+	if timeout > 0 {
+		c.Unary = append(c.Unary, UnaryDeadline(timeout))
+	}
+	return c
+}
+
+// ServerOptions returns the options installing the chain on a server.
+func (c Chain) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.Unary...),
+		grpc.ChainStreamInterceptor(c.Stream...),
+	}
+}
+
+// UnaryRecovery turns a panic of a handler into an Internal error, logging
+// it with its stack trace, instead of crashing the server.
+func UnaryRecovery() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery is UnaryRecovery for streaming calls.
+func StreamRecovery() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, p interface{}) error {
+	slog.ErrorContext(ctx, "panic", "method", method, "panic", p, "stack", string(debug.Stack()))
+	return status.Error(codes.Internal, "internal error")
+}
+
+// UnaryLogging logs the method, latency and status code of every call.
+func UnaryLogging() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamLogging logs streaming calls once they end.
+func StreamLogging() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+// logCall logs a call at info level, or warn when it failed, and error
+// when the service is at fault.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		level = slog.LevelWarn
+		switch code {
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+			level = slog.LevelError
+		}
+	}
+	slog.LogAttrs(ctx, level, "rpc", attrs...)
+}
+
+// UnaryDeadline bounds every unary call to timeout, keeping the deadline
+// of the client when it is sooner. Streams are left alone, as watching may
+// last as long as the client wants.
+func UnaryDeadline(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > timeout {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	rpcRequests = NewCounter("grpc_server_requests_total",
+		"gRPC calls served, by method and status code.", "method", "code")
+	rpcDuration = NewHistogram("grpc_server_request_duration_seconds",
+		"Time taken to serve gRPC calls, by method.", nil, "method")
+)
+
+// UnaryServerInterceptor counts the calls served, their errors by code,
+// and times them.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls,
+// timed until the stream ends.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeCall(method string, start time.Time, err error) {
+	rpcRequests.Inc(method, status.Code(err).String())
+	rpcDuration.Observe(time.Since(start).Seconds(), method)
+}
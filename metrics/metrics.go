@@ -0,0 +1,290 @@
+// Package metrics counts what the services do and serves it at /metrics
+// in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics to expose.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// Default is the registry the metrics of this package are created in.
+var Default = NewRegistry()
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// register adds m, replacing any metric of the same name.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = m
+}
+
+// Expose writes every metric in the text format, sorted by name.
+func (r *Registry) Expose(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	bw.Flush()
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Expose(w)
+	})
+}
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// family is what the metrics of a name share: their help, type and the
+// names of their labels. Every combination of label values is a series.
+type family struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	// Upper bounds of the buckets, for histograms.
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// Histograms only: the count of every bucket, not cumulative, and of
+	// all the observations.
+	buckets []uint64
+	count   uint64
+	fn      func() float64
+}
+
+func newFamily(name, help, typ string, labels []string) *family {
+	f := &family{name: name, help: help, typ: typ, labels: labels, series: map[string]*series{}}
+	Default.register(name, f)
+	return f
+}
+
+// get returns the series of the label values, creating it on first use.
+// Missing values are empty and extra ones ignored.
+func (f *family) get(values []string) *series {
+	vs := make([]string, len(f.labels))
+	copy(vs, values)
+	key := strings.Join(vs, "\xff")
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: vs}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		switch {
+		case s.fn != nil:
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelPairs(s.values, "", ""), formatFloat(s.fn()))
+		case s.buckets != nil:
+			f.writeHistogram(w, s)
+		default:
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelPairs(s.values, "", ""), formatFloat(s.value))
+		}
+	}
+}
+
+func (f *family) labelPairs(values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+escapeLabel(extraValue)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct {
+	f *family
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newFamily(name, help, "counter", labels)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Gauge is a value that goes up and down, such as a number of connections.
+type Gauge struct {
+	f *family
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newFamily(name, help, "gauge", labels)}
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value += v
+}
+
+// Func reads its values when scraped, from statistics kept elsewhere.
+type Func struct {
+	f *family
+}
+
+// NewGaugeFunc creates a gauge whose series are read from functions.
+func NewGaugeFunc(name, help string, labels ...string) *Func {
+	return &Func{newFamily(name, help, "gauge", labels)}
+}
+
+// NewCounterFunc creates a counter whose series are read from functions.
+func NewCounterFunc(name, help string, labels ...string) *Func {
+	return &Func{newFamily(name, help, "counter", labels)}
+}
+
+// Set reads the series of the label values from fn.
+func (f *Func) Set(fn func() float64, labelValues ...string) {
+	f.f.mu.Lock()
+	defer f.f.mu.Unlock()
+	f.f.get(labelValues).fn = fn
+}
+
+// Histogram counts observations, such as latencies, in buckets.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds,
+// sorted, or DefaultBuckets when nil.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	f := newFamily(name, help, "histogram", labels)
+	f.buckets = buckets
+	return &Histogram{f}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.f.buckets))
+	}
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.value += v
+	s.count++
+}
+
+func (f *family) writeHistogram(w io.Writer, s *series) {
+	var cumulative uint64
+	for i, upper := range f.buckets {
+		cumulative += s.buckets[i]
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelPairs(s.values, "le", formatFloat(upper)), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelPairs(s.values, "le", "+Inf"), s.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelPairs(s.values, "", ""), formatFloat(s.value))
+	fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelPairs(s.values, "", ""), s.count)
+}
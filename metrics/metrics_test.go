@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scrape fetches /metrics from the default registry the way Prometheus
+// does and returns its lines.
+func scrape(t *testing.T) []string {
+	t.Helper()
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", got)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(body)), "\n")
+}
+
+func checkLines(t *testing.T, lines []string, want ...string) {
+	t.Helper()
+	scraped := map[string]bool{}
+	for _, l := range lines {
+		scraped[l] = true
+	}
+	for _, w := range want {
+		if !scraped[w] {
+			t.Errorf("missing line %q", w)
+		}
+	}
+}
+
+func TestServerInterceptorsExposeCallsAndLatency(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		stream bool
+		errs   []error
+		want   []string
+	}{
+		{
+			name:   "unary",
+			method: "/metrics.test.Echo/Unary",
+			errs:   []error{nil, nil, status.Error(codes.NotFound, "no such thing")},
+			want: []string{
+				`grpc_server_requests_total{method="/metrics.test.Echo/Unary",code="OK"} 2`,
+				`grpc_server_requests_total{method="/metrics.test.Echo/Unary",code="NotFound"} 1`,
+				`grpc_server_request_duration_seconds_bucket{method="/metrics.test.Echo/Unary",le="+Inf"} 3`,
+				`grpc_server_request_duration_seconds_count{method="/metrics.test.Echo/Unary"} 3`,
+			},
+		},
+		{
+			name:   "stream",
+			method: "/metrics.test.Echo/Stream",
+			stream: true,
+			errs:   []error{status.Error(codes.Unavailable, "gone")},
+			want: []string{
+				`grpc_server_requests_total{method="/metrics.test.Echo/Stream",code="Unavailable"} 1`,
+				`grpc_server_request_duration_seconds_bucket{method="/metrics.test.Echo/Stream",le="+Inf"} 1`,
+				`grpc_server_request_duration_seconds_count{method="/metrics.test.Echo/Stream"} 1`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, callErr := range tt.errs {
+				var err error
+				if tt.stream {
+					err = StreamServerInterceptor()(nil, nil, &grpc.StreamServerInfo{FullMethod: tt.method}, func(interface{}, grpc.ServerStream) error {
+						return callErr
+					})
+				} else {
+					_, err = UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(context.Context, interface{}) (interface{}, error) {
+						return nil, callErr
+					})
+				}
+				if err != callErr {
+					t.Fatalf("interceptor error = %v, want the handler's %v", err, callErr)
+				}
+			}
+
+			lines := scrape(t)
+			checkLines(t, lines, append([]string{
+				"# TYPE grpc_server_requests_total counter",
+				"# TYPE grpc_server_request_duration_seconds histogram",
+			}, tt.want...)...)
+			for _, upper := range []string{"0.005", "0.01", "0.1", "1", "10"} {
+				prefix := `grpc_server_request_duration_seconds_bucket{method="` + tt.method + `",le="` + upper + `"} `
+				found := false
+				for _, l := range lines {
+					found = found || strings.HasPrefix(l, prefix)
+				}
+				if !found {
+					t.Errorf("missing bucket le=%s", upper)
+				}
+			}
+		})
+	}
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	h := NewHistogram("test_histogram_seconds", "A histogram for tests.", []float64{1, 2}, "path")
+	for _, v := range []float64{0.5, 1.5, 1.5, 3} {
+		h.Observe(v, "/a")
+	}
+
+	checkLines(t, scrape(t),
+		"# HELP test_histogram_seconds A histogram for tests.",
+		"# TYPE test_histogram_seconds histogram",
+		`test_histogram_seconds_bucket{path="/a",le="1"} 1`,
+		`test_histogram_seconds_bucket{path="/a",le="2"} 3`,
+		`test_histogram_seconds_bucket{path="/a",le="+Inf"} 4`,
+		`test_histogram_seconds_sum{path="/a"} 6.5`,
+		`test_histogram_seconds_count{path="/a"} 4`,
+	)
+}
+
+func TestCounterEscapesLabelValues(t *testing.T) {
+	c := NewCounter("test_escaped_total", "Help with a \\ and a\nnewline.", "value")
+	c.Inc(`say "hi"\now`)
+	c.Add(-1, `say "hi"\now`)
+
+	checkLines(t, scrape(t),
+		`# HELP test_escaped_total Help with a \\ and a\nnewline.`,
+		`test_escaped_total{value="say \"hi\"\\now"} 1`,
+	)
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+var (
+	dbOpenConnections = NewGaugeFunc("db_open_connections",
+		"Connections open to the database, in use or idle.", "db")
+	dbInUseConnections = NewGaugeFunc("db_in_use_connections",
+		"Connections in use.", "db")
+	dbIdleConnections = NewGaugeFunc("db_idle_connections",
+		"Idle connections.", "db")
+	dbWaitCount = NewCounterFunc("db_wait_count_total",
+		"Times a connection had to be waited for.", "db")
+	dbWaitDuration = NewCounterFunc("db_wait_duration_seconds_total",
+		"Time spent waiting for connections.", "db")
+	dbClosedMaxIdle = NewCounterFunc("db_closed_max_idle_total",
+		"Connections closed for exceeding the idle limit.", "db")
+	dbClosedMaxLifetime = NewCounterFunc("db_closed_max_lifetime_total",
+		"Connections closed for exceeding their lifetime.", "db")
+)
+
+// RegisterDB exposes the connection pool statistics of db under the name.
+// Registering a name again replaces the database it reads from.
+func RegisterDB(name string, db *sql.DB) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 {
+			return read(db.Stats())
+		}
+	}
+
+	dbOpenConnections.Set(stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }), name)
+	dbInUseConnections.Set(stat(func(s sql.DBStats) float64 { return float64(s.InUse) }), name)
+	dbIdleConnections.Set(stat(func(s sql.DBStats) float64 { return float64(s.Idle) }), name)
+	dbWaitCount.Set(stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }), name)
+	dbWaitDuration.Set(stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }), name)
+	dbClosedMaxIdle.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }), name)
+	dbClosedMaxLifetime.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }), name)
+}
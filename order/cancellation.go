@@ -0,0 +1,69 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrNotOwner       = errors.New("order does not belong to account")
+	ErrNotCancellable = errors.New("order can no longer be cancelled")
+)
+
+const defaultCancelReason = "cancelled by customer"
+
+// cancellationStaleAfter is how long an order stays cancelling before
+// ResumeCancellations runs its hooks again, leaving a Cancel in flight
+// the time to finish.
+const cancellationStaleAfter = 2 * time.Minute
+
+// CancellationHook performs a compensating action when an order is
+// cancelled, such as releasing reserved stock or refunding a payment.
+//
+// Hooks run in registration order once the order is cancelling, so that it
+// can no longer be paid or shipped meanwhile. If one fails the order stays
+// cancelling, and a retried Cancel or ResumeCancellations runs every hook
+// again: implementations must be idempotent.
+type CancellationHook interface {
+	Name() string
+	Compensate(ctx context.Context, o Order, reason string) error
+}
+
+type cancellationHookFunc struct {
+	name string
+	fn   func(ctx context.Context, o Order, reason string) error
+}
+
+// NewCancellationHook wraps fn into a CancellationHook.
+// It is handy for wiring simple compensations and in-process fakes.
+func NewCancellationHook(name string, fn func(ctx context.Context, o Order, reason string) error) CancellationHook {
+	return &cancellationHookFunc{name: name, fn: fn}
+}
+
+func (h *cancellationHookFunc) Name() string {
+	return h.name
+}
+
+func (h *cancellationHookFunc) Compensate(ctx context.Context, o Order, reason string) error {
+	return h.fn(ctx, o, reason)
+}
+
+// RecoverCancellations completes the cancellations left halfway every
+// interval until the context is done.
+func RecoverCancellations(ctx context.Context, s Service, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ResumeCancellations(ctx); err != nil {
+				slog.ErrorContext(ctx, "cancellations not resumed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCancel(t *testing.T) {
+	errHook := errors.New("hook failed")
+
+	tests := []struct {
+		name      string
+		status    Status
+		accountId string
+		// failing names the hook that fails, if any.
+		failing    string
+		wantErr    error
+		wantStatus Status
+		wantHooks  []string
+	}{
+		{name: "pending", status: StatusPending, accountId: "acc", wantStatus: StatusCancelled, wantHooks: []string{"stock", "payment"}},
+		{name: "paid", status: StatusPaid, accountId: "acc", wantStatus: StatusCancelled, wantHooks: []string{"stock", "payment"}},
+		{name: "fulfilled", status: StatusFulfilled, accountId: "acc", wantErr: ErrNotCancellable, wantStatus: StatusFulfilled},
+		{name: "shipped", status: StatusShipped, accountId: "acc", wantErr: ErrNotCancellable, wantStatus: StatusShipped},
+		{name: "delivered", status: StatusDelivered, accountId: "acc", wantErr: ErrNotCancellable, wantStatus: StatusDelivered},
+		{name: "already cancelled", status: StatusCancelled, accountId: "acc", wantErr: ErrNotCancellable, wantStatus: StatusCancelled},
+		{name: "left cancelling", status: StatusCancelling, accountId: "acc", wantStatus: StatusCancelled, wantHooks: []string{"stock", "payment"}},
+		{name: "other account", status: StatusPending, accountId: "other", wantErr: ErrNotOwner, wantStatus: StatusPending},
+		{name: "failing hook", status: StatusPaid, accountId: "acc", failing: "payment", wantErr: errHook, wantStatus: StatusCancelling, wantHooks: []string{"stock", "payment"}},
+		{name: "first hook failing", status: StatusPaid, accountId: "acc", failing: "stock", wantErr: errHook, wantStatus: StatusCancelling, wantHooks: []string{"stock"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := Order{ID: "o1", AccountId: "acc", Status: tt.status}
+			if tt.status == StatusCancelling {
+				stored.History = []StatusChange{{From: StatusPaid, To: StatusCancelling, Actor: "acc", Reason: defaultCancelReason}}
+			}
+			repository := newFakeRepository(stored)
+
+			var ran []string
+			hook := func(name string) CancellationHook {
+				return NewCancellationHook(name, func(ctx context.Context, o Order, reason string) error {
+					ran = append(ran, name)
+					if name == tt.failing {
+						return errHook
+					}
+					return nil
+				})
+			}
+			s := NewService(repository, WithCancellationHooks(hook("stock"), hook("payment")))
+
+			o, err := s.Cancel(context.Background(), "o1", tt.accountId, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Cancel() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := repository.status("o1"); got != tt.wantStatus {
+				t.Errorf("order is %s, want %s", got, tt.wantStatus)
+			}
+			if !slices.Equal(ran, tt.wantHooks) {
+				t.Errorf("hooks ran %v, want %v", ran, tt.wantHooks)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			requested, last := o.History[len(o.History)-2], o.History[len(o.History)-1]
+			if requested.To != StatusCancelling || requested.Actor != tt.accountId || requested.Reason != defaultCancelReason {
+				t.Errorf("cancellation requested with %+v", requested)
+			}
+			if last.From != StatusCancelling || last.To != StatusCancelled || last.Actor != tt.accountId || last.Reason != defaultCancelReason {
+				t.Errorf("last status change = %+v", last)
+			}
+		})
+	}
+}
+
+func TestCancelUnknownOrder(t *testing.T) {
+	s := NewService(newFakeRepository())
+	if _, err := s.Cancel(context.Background(), "missing", "acc", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Cancel() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// TestCancelHoldsOffFulfilment ships the order while the hooks give the
+// money back, which must fail rather than ship a refunded order.
+func TestCancelHoldsOffFulfilment(t *testing.T) {
+	repository := newFakeRepository(Order{ID: "o1", AccountId: "acc", Status: StatusPaid})
+	var s Service
+	var shipErr error
+	s = NewService(repository, WithCancellationHooks(NewCancellationHook("payment", func(ctx context.Context, o Order, reason string) error {
+		_, shipErr = s.UpdateStatus(ctx, o.ID, StatusFulfilled, "admin", "shipment created")
+		return nil
+	})))
+
+	if _, err := s.Cancel(context.Background(), "o1", "acc", ""); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if !errors.Is(shipErr, ErrInvalidTransition) {
+		t.Errorf("fulfilling during the hooks: error = %v, want %v", shipErr, ErrInvalidTransition)
+	}
+	if got := repository.status("o1"); got != StatusCancelled {
+		t.Errorf("order is %s, want %s", got, StatusCancelled)
+	}
+}
+
+func TestResumeCancellations(t *testing.T) {
+	errHook := errors.New("hook failed")
+	now := time.Now().UTC()
+	cancelling := func(id string, at time.Time) Order {
+		return Order{ID: id, AccountId: "acc", Status: StatusCancelling, History: []StatusChange{
+			{From: StatusPaid, To: StatusCancelling, Actor: "acc", Reason: "changed my mind", CreatedAt: at},
+		}}
+	}
+	repository := newFakeRepository(
+		cancelling("stale", now.Add(-time.Hour)),
+		cancelling("failing", now.Add(-time.Hour)),
+		cancelling("recent", now),
+		Order{ID: "paid", AccountId: "acc", Status: StatusPaid},
+	)
+
+	var compensated []string
+	s := NewService(repository, WithCancellationHooks(NewCancellationHook("payment", func(ctx context.Context, o Order, reason string) error {
+		compensated = append(compensated, o.ID)
+		if reason != "changed my mind" {
+			t.Errorf("hook got reason %q, want the requested one", reason)
+		}
+		if o.ID == "failing" {
+			return errHook
+		}
+		return nil
+	})))
+
+	if err := s.ResumeCancellations(context.Background()); err != nil {
+		t.Fatalf("ResumeCancellations() error = %v", err)
+	}
+
+	slices.Sort(compensated)
+	if want := []string{"failing", "stale"}; !slices.Equal(compensated, want) {
+		t.Errorf("compensated %v, want %v", compensated, want)
+	}
+	want := map[string]Status{"stale": StatusCancelled, "failing": StatusCancelling, "recent": StatusCancelling, "paid": StatusPaid}
+	for id, status := range want {
+		if got := repository.status(id); got != status {
+			t.Errorf("order %s is %s, want %s", id, got, status)
+		}
+	}
+	if o, _ := repository.GetById(context.Background(), "stale"); o.History[len(o.History)-1].Actor != "acc" {
+		t.Errorf("cancelled by %q, want the customer", o.History[len(o.History)-1].Actor)
+	}
+}
@@ -0,0 +1,189 @@
+package order
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lichb0rn/go-microservices/account"
+	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/payment"
+	"github.com/lichb0rn/go-microservices/promotion"
+	"github.com/lichb0rn/go-microservices/shipping"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrAccountNotFound  = errors.New("account not found")
+	ErrProductsNotFound = errors.New("products not found")
+)
+
+// Checkout is everything needed to place an order. It is the payload of
+// the checkout saga: steps fill it in as they run and it is persisted after
+// each of them.
+type Checkout struct {
+	OrderID   string `json:"orderId"`
+	AccountID string `json:"accountId"`
+	// Requested holds the product ids and quantities asked for,
+	// Products the same lines priced from the catalog.
+	Requested       []OrderedProduct `json:"requested"`
+	Products        []OrderedProduct `json:"products"`
+	IdempotencyKey  IdempotencyKey   `json:"idempotencyKey"`
+	Coupon          string           `json:"coupon,omitempty"`
+	ShippingAddress Address          `json:"shippingAddress"`
+	ShippingMethod  string           `json:"shippingMethod,omitempty"`
+	// Order is the order placed by the place_order step.
+	Order     *Order `json:"order,omitempty"`
+	PaymentID string `json:"paymentId,omitempty"`
+	// Replayed is set when a concurrent attempt with the same idempotency
+	// key placed the order first. OrderID is then the id of its order, and
+	// the payment is left to that attempt.
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// checkoutActor is the actor of the status changes made by the saga.
+const checkoutActor = "checkout"
+
+// NewCheckoutSteps returns the steps placing an order, in order. The
+// payment steps are left out when paymentClient is nil. Stock is not
+// reserved, as there is no inventory service to reserve it with.
+func NewCheckoutSteps(s Service, accountClient *account.Client, catalogClient *catalog.Client, paymentClient *payment.Client) []SagaStep {
+	steps := []SagaStep{
+		&accountStep{accountClient},
+		&pricingStep{catalogClient},
+		&placeOrderStep{s},
+	}
+	if paymentClient != nil {
+		steps = append(steps,
+			&authorizePaymentStep{paymentClient},
+			&capturePaymentStep{paymentClient, s},
+		)
+	}
+	return steps
+}
+
+type accountStep struct {
+	client *account.Client
+}
+
+func (s *accountStep) Name() string { return "verify_account" }
+
+func (s *accountStep) Execute(ctx context.Context, c *Checkout) error {
+	_, err := s.client.GetOne(ctx, c.AccountID)
+	if status.Code(err) == codes.NotFound {
+		return Permanent(ErrAccountNotFound)
+	}
+	return err
+}
+
+func (s *accountStep) Compensate(ctx context.Context, c *Checkout) error {
+	return nil
+}
+
+type pricingStep struct {
+	client *catalog.Client
+}
+
+func (s *pricingStep) Name() string { return "price_products" }
+
+func (s *pricingStep) Execute(ctx context.Context, c *Checkout) error {
+	products, err := priceProducts(ctx, s.client, c.Requested)
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return Permanent(ErrProductsNotFound)
+	}
+	c.Products = products
+	return nil
+}
+
+// priceProducts completes the requested lines from the catalog, leaving out
+// the products it does not know.
+func priceProducts(ctx context.Context, client *catalog.Client, requested []OrderedProduct) ([]OrderedProduct, error) {
+	productIds := make([]string, 0, len(requested))
+	for _, rp := range requested {
+		productIds = append(productIds, rp.ID)
+	}
+
+	catalogProducts, err := client.GetProducts(ctx, 0, 0, productIds, "")
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]OrderedProduct, 0, len(catalogProducts))
+	for _, p := range catalogProducts {
+		product := OrderedProduct{
+			ID:          p.ID,
+			Price:       p.Price,
+			Name:        p.Name,
+			Description: p.Description,
+			TaxClass:    p.TaxClass,
+			Weight:      p.Weight,
+			Quantity:    0,
+		}
+
+		for _, rp := range requested {
+			if rp.ID == p.ID {
+				product.Quantity = rp.Quantity
+				break
+			}
+		}
+
+		if product.Quantity > 0 {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+func (s *pricingStep) Compensate(ctx context.Context, c *Checkout) error {
+	return nil
+}
+
+type placeOrderStep struct {
+	service Service
+}
+
+func (s *placeOrderStep) Name() string { return "place_order" }
+
+func (s *placeOrderStep) Execute(ctx context.Context, c *Checkout) error {
+	o, err := s.service.Post(ctx, *c)
+	if errors.Is(err, ErrIdempotencyMismatch) || isCouponError(err) || isShippingError(err) {
+		return Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if o.ID != c.OrderID {
+		c.OrderID = o.ID
+		c.Replayed = true
+	}
+	c.Order = o
+	return nil
+}
+
+// Compensate cancels the order if it was stored before the saga failed,
+// keeping it for the records instead of deleting it. A replayed order
+// belongs to the attempt that placed it and is left alone.
+func (s *placeOrderStep) Compensate(ctx context.Context, c *Checkout) error {
+	if c.Replayed {
+		return nil
+	}
+	_, err := s.service.UpdateStatus(ctx, c.OrderID, StatusCancelled, checkoutActor, "checkout aborted")
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidTransition) {
+		return nil
+	}
+	return err
+}
+
+func isCouponError(err error) bool {
+	return errors.Is(err, promotion.ErrCouponNotFound) ||
+		errors.Is(err, promotion.ErrCouponInactive) ||
+		errors.Is(err, promotion.ErrCouponExhausted) ||
+		errors.Is(err, promotion.ErrCouponNotApplicable)
+}
+
+func isShippingError(err error) bool {
+	return errors.Is(err, shipping.ErrMethodNotFound) || errors.Is(err, shipping.ErrMethodUnavailable)
+}
@@ -0,0 +1,98 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// placedService is a Service whose Post returns an order placed earlier,
+// like one placed by a concurrent attempt with the same idempotency key.
+// The methods a test does not need panic if called.
+type placedService struct {
+	Service
+
+	placed    Order
+	cancelled []string
+}
+
+func (s *placedService) Post(ctx context.Context, c Checkout) (*Order, error) {
+	o := s.placed
+	return &o, nil
+}
+
+func (s *placedService) UpdateStatus(ctx context.Context, id string, status Status, actor, reason string) (*Order, error) {
+	s.cancelled = append(s.cancelled, id)
+	return nil, ErrInvalidTransition
+}
+
+func TestCheckoutReplayLeavesPaymentToFirstAttempt(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    bool
+		wantErr error
+	}{
+		{name: "completes"},
+		{name: "fails after placing", fail: true, wantErr: errInjected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &placedService{placed: Order{ID: "first", Status: StatusPending}}
+			// The payment steps have no client and panic if they run.
+			steps := []SagaStep{
+				&placeOrderStep{service},
+				&authorizePaymentStep{},
+				&capturePaymentStep{},
+			}
+			if tt.fail {
+				steps = append(steps, &faultyStep{name: "after", journal: &journal{}, executeFailures: -1, permanent: true})
+			}
+			saga := NewSaga(newMemorySagaLog(), testRetryPolicy, steps...)
+
+			c := &Checkout{OrderID: "second"}
+			if err := saga.Run(context.Background(), c); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if !c.Replayed || c.OrderID != "first" {
+				t.Errorf("checkout = %s replayed %t, want first replayed", c.OrderID, c.Replayed)
+			}
+			if len(service.cancelled) != 0 {
+				t.Errorf("cancelled %v, want the first attempt's order left alone", service.cancelled)
+			}
+		})
+	}
+}
+
+func TestReplayed(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		status  Status
+		actor   string
+		wantErr error
+	}{
+		{name: "pending", status: StatusPending, actor: "account"},
+		{name: "paid", status: StatusPaid, actor: "payment"},
+		{name: "cancelled by the customer", status: StatusCancelled, actor: "account"},
+		{name: "aborted checkout", status: StatusCancelled, actor: checkoutActor, wantErr: ErrCheckoutAborted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Order{ID: "1", Status: tt.status, History: []StatusChange{
+				{To: StatusPending, Actor: "account", CreatedAt: now},
+				{To: tt.status, Actor: tt.actor, CreatedAt: now},
+			}}
+
+			got, err := replayed(o)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("replayed() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != o {
+				t.Errorf("replayed() = %v, want the order", got)
+			}
+		})
+	}
+}
@@ -2,12 +2,16 @@ package order
 
 import (
 	"context"
-	"log"
+	"io"
+	"log/slog"
 	"time"
 
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
 	"github.com/lichb0rn/go-microservices/order/pb"
+	"github.com/lichb0rn/go-microservices/promotion"
+	"github.com/lichb0rn/go-microservices/shipping"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
@@ -15,8 +19,9 @@ type Client struct {
 	service pb.OrderServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, cfg grpcclient.Config) (*Client, error) {
+	conn, err := grpcclient.Dial(url, "order", cfg,
+		"GetOrder", "GetByAccountId", "GetShippingQuotes", "GetShipments", "GetReturns", "GetSalesReport")
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +35,20 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
-func (c *Client) Post(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error) {
+// Health fails unless the service reports itself serving.
+func (c *Client) Health(ctx context.Context) error {
+	return health.Downstream(c.conn)(ctx)
+}
+
+// PostOptions holds the optional parts of an order request.
+type PostOptions struct {
+	IdempotencyKey  string
+	Coupon          string
+	ShippingAddress Address
+	ShippingMethod  string
+}
+
+func (c *Client) Post(ctx context.Context, accountId string, products []OrderedProduct, opts PostOptions) (*Order, error) {
 	pbProducts := make([]*pb.PostOrderRequest_OrderProduct, 0, len(products))
 	for _, p := range products {
 		pbProducts = append(pbProducts, &pb.PostOrderRequest_OrderProduct{
@@ -39,57 +57,392 @@ func (c *Client) Post(ctx context.Context, accountId string, products []OrderedP
 		})
 	}
 	r, err := c.service.PostOrder(ctx, &pb.PostOrderRequest{
-		AccountId: accountId,
-		Products:  pbProducts,
+		AccountId:       accountId,
+		Products:        pbProducts,
+		IdempotencyKey:  opts.IdempotencyKey,
+		Coupon:          opts.Coupon,
+		ShippingAddress: addressToProto(opts.ShippingAddress),
+		ShippingMethod:  opts.ShippingMethod,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	newOrder := r.Order
-	createdAt := time.Time{}
-	createdAt.UnmarshalBinary(newOrder.CreatedAt)
+	newOrder := orderFromProto(r.Order)
+	return &newOrder, nil
+}
 
-	return &Order{
-		ID:         newOrder.Id,
-		CreatedAt:  createdAt,
-		TotalPrice: newOrder.TotalPrice,
-		AccountId:  newOrder.AccountId,
-		Products:   products,
-	}, nil
+func (c *Client) GetOne(ctx context.Context, id string) (*Order, error) {
+	r, err := c.service.GetOrder(ctx, &pb.GetOrderRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	o := orderFromProto(r.Order)
+	return &o, nil
 }
 
-func (c *Client) GetByAccountId(ctx context.Context, accountId string) ([]Order, error) {
-	r, err := c.service.GetByAccountId(ctx, &pb.GetOrdersForAccountRequest{AccountId: accountId})
+func (c *Client) GetByAccountId(ctx context.Context, accountId string, filter OrderFilter) (*OrderPage, error) {
+	req := &pb.GetOrdersForAccountRequest{
+		AccountId:  accountId,
+		MinTotal:   filter.MinTotal,
+		MaxTotal:   filter.MaxTotal,
+		Descending: filter.Descending,
+		Take:       filter.Take,
+		After:      filter.After,
+	}
+	if !filter.CreatedAfter.IsZero() {
+		req.CreatedAfter, _ = filter.CreatedAfter.MarshalBinary()
+	}
+	if !filter.CreatedBefore.IsZero() {
+		req.CreatedBefore, _ = filter.CreatedBefore.MarshalBinary()
+	}
+	for _, s := range filter.Statuses {
+		req.Statuses = append(req.Statuses, string(s))
+	}
+
+	r, err := c.service.GetByAccountId(ctx, req)
 	if err != nil {
-		log.Println("Orders not found: ", err)
+		slog.WarnContext(ctx, "orders not found", "error", err)
 		return nil, err
 	}
 
 	orders := make([]Order, 0, len(r.Orders))
 	for _, o := range r.Orders {
-		newOrder := Order{
-			ID:         o.Id,
-			TotalPrice: o.TotalPrice,
-			AccountId:  o.AccountId,
+		orders = append(orders, orderFromProto(o))
+	}
+
+	return &OrderPage{Orders: orders, NextCursor: r.NextCursor}, nil
+}
+
+func (c *Client) UpdateStatus(ctx context.Context, id string, status Status, actor, reason string) (*Order, error) {
+	r, err := c.service.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{
+		Id:     id,
+		Status: string(status),
+		Actor:  actor,
+		Reason: reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o := orderFromProto(r.Order)
+	return &o, nil
+}
+
+func (c *Client) Cancel(ctx context.Context, id, accountId, reason string) (*Order, error) {
+	r, err := c.service.CancelOrder(ctx, &pb.CancelOrderRequest{
+		Id:        id,
+		AccountId: accountId,
+		Reason:    reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o := orderFromProto(r.Order)
+	return &o, nil
+}
+
+func orderFromProto(o *pb.Order) Order {
+	newOrder := Order{
+		ID:              o.Id,
+		Subtotal:        o.Subtotal,
+		DiscountTotal:   o.DiscountTotal,
+		TaxTotal:        o.TaxTotal,
+		TotalPrice:      o.TotalPrice,
+		AccountId:       o.AccountId,
+		Status:          Status(o.Status),
+		Coupon:          o.Coupon,
+		FreeShipping:    o.FreeShipping,
+		ShippingAddress: addressFromProto(o.ShippingAddress),
+		ShippingMethod:  o.ShippingMethod,
+		ShippingCost:    o.ShippingCost,
+	}
+	newOrder.CreatedAt = time.Time{}
+	newOrder.CreatedAt.UnmarshalBinary(o.CreatedAt)
+
+	for _, c := range o.History {
+		change := StatusChange{
+			From:   Status(c.From),
+			To:     Status(c.To),
+			Actor:  c.Actor,
+			Reason: c.Reason,
+		}
+		change.CreatedAt.UnmarshalBinary(c.CreatedAt)
+		newOrder.History = append(newOrder.History, change)
+	}
+
+	products := make([]OrderedProduct, 0, len(o.Products))
+	for _, p := range o.Products {
+		products = append(products, OrderedProduct{
+			ID:          p.Id,
+			Price:       p.Price,
+			Name:        p.Name,
+			Description: p.Description,
+			Quantity:    int(p.Quantity),
+			Discount:    p.Discount,
+			TaxClass:    p.TaxClass,
+			TaxRate:     p.TaxRate,
+			Tax:         p.Tax,
+		})
+	}
+	newOrder.Products = products
+
+	for _, d := range o.Discounts {
+		newOrder.Discounts = append(newOrder.Discounts, promotion.Discount{
+			PromotionID: d.PromotionId,
+			Code:        d.Code,
+			Kind:        promotion.Kind(d.Kind),
+			ProductID:   d.ProductId,
+			Amount:      d.Amount,
+		})
+	}
+
+	return newOrder
+}
+
+func (c *Client) CreatePromotion(ctx context.Context, p promotion.Promotion) (*promotion.Promotion, error) {
+	r, err := c.service.CreatePromotion(ctx, &pb.CreatePromotionRequest{Promotion: promotionToProto(p)})
+	if err != nil {
+		return nil, err
+	}
+	created := promotionFromProto(r.Promotion)
+	return &created, nil
+}
+
+func (c *Client) GetShippingQuotes(ctx context.Context, products []OrderedProduct, address Address) ([]shipping.Quote, error) {
+	lines := make([]*pb.GetShippingQuotesRequest_Line, 0, len(products))
+	for _, p := range products {
+		lines = append(lines, &pb.GetShippingQuotesRequest_Line{
+			ProductId: p.ID,
+			Quantity:  uint32(p.Quantity),
+		})
+	}
+	r, err := c.service.GetShippingQuotes(ctx, &pb.GetShippingQuotesRequest{
+		Lines:   lines,
+		Address: addressToProto(address),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]shipping.Quote, 0, len(r.Quotes))
+	for _, q := range r.Quotes {
+		quotes = append(quotes, shipping.Quote{MethodID: q.MethodId, Name: q.Name, Cost: q.Cost})
+	}
+	return quotes, nil
+}
+
+func (c *Client) CreateShipment(ctx context.Context, orderId, carrier string, lines []ShipmentLine) (*Shipment, error) {
+	pl := make([]*pb.Shipment_Line, 0, len(lines))
+	for _, l := range lines {
+		pl = append(pl, &pb.Shipment_Line{ProductId: l.ProductID, Quantity: uint32(l.Quantity)})
+	}
+	r, err := c.service.CreateShipment(ctx, &pb.CreateShipmentRequest{
+		OrderId: orderId,
+		Carrier: carrier,
+		Lines:   pl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := shipmentFromProto(r.Shipment)
+	return &s, nil
+}
+
+func (c *Client) GetShipments(ctx context.Context, orderId string) ([]Shipment, error) {
+	r, err := c.service.GetShipments(ctx, &pb.GetShipmentsRequest{OrderId: orderId})
+	if err != nil {
+		return nil, err
+	}
+
+	shipments := make([]Shipment, 0, len(r.Shipments))
+	for _, s := range r.Shipments {
+		shipments = append(shipments, shipmentFromProto(s))
+	}
+	return shipments, nil
+}
+
+func shipmentFromProto(ps *pb.Shipment) Shipment {
+	s := Shipment{
+		ID:             ps.Id,
+		OrderID:        ps.OrderId,
+		Carrier:        ps.Carrier,
+		TrackingNumber: ps.TrackingNumber,
+		Status:         ShipmentStatus(ps.Status),
+		Lines:          make([]ShipmentLine, 0, len(ps.Lines)),
+		Events:         make([]TrackingEvent, 0, len(ps.Events)),
+	}
+	s.CreatedAt.UnmarshalBinary(ps.CreatedAt)
+	s.UpdatedAt.UnmarshalBinary(ps.UpdatedAt)
+	for _, l := range ps.Lines {
+		s.Lines = append(s.Lines, ShipmentLine{ProductID: l.ProductId, Quantity: int(l.Quantity)})
+	}
+	for _, pe := range ps.Events {
+		e := TrackingEvent{
+			Status:      ShipmentStatus(pe.Status),
+			Description: pe.Description,
+			Location:    pe.Location,
 		}
-		newOrder.CreatedAt = time.Time{}
-		newOrder.CreatedAt.UnmarshalBinary(o.CreatedAt)
-
-		products := make([]OrderedProduct, 0, len(o.Products))
-		for _, p := range o.Products {
-			products = append(products, OrderedProduct{
-				ID:          p.Id,
-				Price:       p.Price,
-				Name:        p.Name,
-				Description: p.Description,
-				Quantity:    int(p.Quantity),
-			})
+		e.OccurredAt.UnmarshalBinary(pe.OccurredAt)
+		s.Events = append(s.Events, e)
+	}
+	return s
+}
+
+func (c *Client) RequestReturn(ctx context.Context, orderId, accountId, reason string, lines []ReturnLine) (*Return, error) {
+	pl := make([]*pb.Return_Line, 0, len(lines))
+	for _, l := range lines {
+		pl = append(pl, &pb.Return_Line{ProductId: l.ProductID, Quantity: uint32(l.Quantity)})
+	}
+	r, err := c.service.RequestReturn(ctx, &pb.RequestReturnRequest{
+		OrderId:   orderId,
+		AccountId: accountId,
+		Reason:    reason,
+		Lines:     pl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := returnFromProto(r.Return)
+	return &ret, nil
+}
+
+func (c *Client) ApproveReturn(ctx context.Context, id, note string) (*Return, error) {
+	return returnFromResponse(c.service.ApproveReturn(ctx, &pb.ReviewReturnRequest{Id: id, Note: note}))
+}
+
+func (c *Client) RejectReturn(ctx context.Context, id, note string) (*Return, error) {
+	return returnFromResponse(c.service.RejectReturn(ctx, &pb.ReviewReturnRequest{Id: id, Note: note}))
+}
+
+func (c *Client) ReceiveReturn(ctx context.Context, id string) (*Return, error) {
+	return returnFromResponse(c.service.ReceiveReturn(ctx, &pb.ReturnIdRequest{Id: id}))
+}
+
+func (c *Client) RefundReturn(ctx context.Context, id string) (*Return, error) {
+	return returnFromResponse(c.service.RefundReturn(ctx, &pb.ReturnIdRequest{Id: id}))
+}
+
+func (c *Client) GetReturns(ctx context.Context, orderId string) ([]Return, error) {
+	r, err := c.service.GetReturns(ctx, &pb.GetReturnsRequest{OrderId: orderId})
+	if err != nil {
+		return nil, err
+	}
+
+	returns := make([]Return, 0, len(r.Returns))
+	for _, ret := range r.Returns {
+		returns = append(returns, returnFromProto(ret))
+	}
+	return returns, nil
+}
+
+func returnFromResponse(r *pb.ReturnResponse, err error) (*Return, error) {
+	if err != nil {
+		return nil, err
+	}
+	ret := returnFromProto(r.Return)
+	return &ret, nil
+}
+
+func returnFromProto(pr *pb.Return) Return {
+	r := Return{
+		ID:           pr.Id,
+		OrderID:      pr.OrderId,
+		AccountID:    pr.AccountId,
+		Status:       ReturnStatus(pr.Status),
+		Reason:       pr.Reason,
+		Note:         pr.Note,
+		RefundAmount: pr.RefundAmount,
+		Lines:        make([]ReturnLine, 0, len(pr.Lines)),
+	}
+	r.CreatedAt.UnmarshalBinary(pr.CreatedAt)
+	r.UpdatedAt.UnmarshalBinary(pr.UpdatedAt)
+	for _, l := range pr.Lines {
+		r.Lines = append(r.Lines, ReturnLine{
+			ProductID: l.ProductId,
+			Quantity:  int(l.Quantity),
+			Amount:    l.Amount,
+		})
+	}
+	return r
+}
+
+// WatchOrders receives the events of the account's orders, or of all
+// orders when accountId is empty. The channel is closed when ctx is done
+// or the stream breaks.
+func (c *Client) WatchOrders(ctx context.Context, accountId string) (<-chan OrderEvent, error) {
+	stream, err := c.service.WatchOrders(ctx, &pb.WatchOrdersRequest{AccountId: accountId})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan OrderEvent)
+	go func() {
+		defer close(events)
+		for {
+			pe, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					slog.WarnContext(ctx, "order events stream broken", "error", err)
+				}
+				return
+			}
+
+			e := OrderEvent{
+				Type:  EventType(pe.Type),
+				Order: orderFromProto(pe.Order),
+				From:  Status(pe.From),
+			}
+			e.OccurredAt.UnmarshalBinary(pe.OccurredAt)
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+	return events, nil
+}
 
-		newOrder.Products = products
-		orders = append(orders, newOrder)
+func (c *Client) GetSalesReport(ctx context.Context, f ReportFilter) (*SalesReport, error) {
+	req := &pb.GetSalesReportRequest{Period: string(f.Period), Top: uint32(f.Top)}
+	req.From, _ = f.From.MarshalBinary()
+	req.To, _ = f.To.MarshalBinary()
+	r, err := c.service.GetSalesReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := r.Report
+	report := &SalesReport{
+		Period:            ReportPeriod(pr.Period),
+		Revenue:           make([]RevenuePoint, 0, len(pr.Revenue)),
+		TotalRevenue:      pr.TotalRevenue,
+		Orders:            int(pr.Orders),
+		AverageOrderValue: pr.AverageOrderValue,
+		TopByUnits:        productSalesFromProto(pr.TopByUnits),
+		TopByRevenue:      productSalesFromProto(pr.TopByRevenue),
+		Customers: CustomerStats{
+			New:       int(pr.NewCustomers),
+			Returning: int(pr.ReturningCustomers),
+		},
 	}
+	report.From.UnmarshalBinary(pr.From)
+	report.To.UnmarshalBinary(pr.To)
+	for _, p := range pr.Revenue {
+		point := RevenuePoint{Orders: int(p.Orders), Revenue: p.Revenue}
+		point.Start.UnmarshalBinary(p.Start)
+		report.Revenue = append(report.Revenue, point)
+	}
+	return report, nil
+}
 
-	return orders, nil
+func productSalesFromProto(products []*pb.SalesReport_ProductSales) []ProductSales {
+	result := make([]ProductSales, 0, len(products))
+	for _, p := range products {
+		result = append(result, ProductSales{ProductID: p.ProductId, Units: int(p.Units), Revenue: p.Revenue})
+	}
+	return result
 }
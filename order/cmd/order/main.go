@@ -1,18 +1,64 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/lichb0rn/go-microservices/account"
+	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/events"
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/metrics"
 	"github.com/lichb0rn/go-microservices/order"
+	"github.com/lichb0rn/go-microservices/payment"
+	"github.com/lichb0rn/go-microservices/tracing"
 	"github.com/tinrab/kit/retry"
 )
 
 type Config struct {
-	DatabaseURL string `envconfig:"DATABASE_URL"`
-	AccountURL  string `envconfig:"ACCOUNT_SERVICE_URL"`
-	CatalogURL  string `envconfig:"CATALOG_SERVICE_URL"`
+	DatabaseURL  string `envconfig:"DATABASE_URL"`
+	AccountURL   string `envconfig:"ACCOUNT_SERVICE_URL"`
+	CatalogURL   string `envconfig:"CATALOG_SERVICE_URL"`
+	PaymentURL   string `envconfig:"PAYMENT_SERVICE_URL"`
+	EventBusURL  string `envconfig:"EVENT_BUS_URL"`
+	EventChannel string `envconfig:"EVENT_CHANNEL" default:"events"`
+	// TrackingInterval is how often the carriers are polled for tracking events.
+	TrackingInterval time.Duration `envconfig:"TRACKING_INTERVAL" default:"1m"`
+	// OutboxInterval is how often the outbox is relayed to the event bus.
+	OutboxInterval time.Duration `envconfig:"OUTBOX_INTERVAL" default:"1s"`
+	// SagaRecoveryInterval is how often abandoned checkout sagas are rolled back.
+	SagaRecoveryInterval time.Duration `envconfig:"SAGA_RECOVERY_INTERVAL" default:"1m"`
+	// CancellationRecoveryInterval is how often the cancellations left
+	// halfway are completed.
+	CancellationRecoveryInterval time.Duration `envconfig:"CANCELLATION_RECOVERY_INTERVAL" default:"1m"`
+	// RefundRecoveryInterval is how often the returns left refunding are
+	// refunded again.
+	RefundRecoveryInterval time.Duration `envconfig:"REFUND_RECOVERY_INTERVAL" default:"1m"`
+	// RequestTimeout bounds every unary call; WatchOrders streams are not bounded.
+	RequestTimeout time.Duration `envconfig:"REQUEST_TIMEOUT" default:"10s"`
+	// HealthInterval is how often the dependencies are checked.
+	HealthInterval time.Duration `envconfig:"HEALTH_INTERVAL" default:"5s"`
+	// ShutdownTimeout is how long the calls in flight get to finish on shutdown.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"8s"`
+	TraceExporter   string        `envconfig:"TRACE_EXPORTER" default:"none"`
+	// LogFormat is json or text, and LogLevel debug, info, warn or error.
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+	// Client tunes the calls to the other services, from CLIENT_TIMEOUT,
+	// CLIENT_RETRY_ATTEMPTS, CLIENT_BREAKER_FAILURES and the like.
+	Client grpcclient.Config `envconfig:"CLIENT"`
+	// MetricsAddr is where /metrics is served, apart from the gRPC port.
+	MetricsAddr string `envconfig:"METRICS_ADDR" default:":9090"`
 }
 
 func main() {
@@ -22,16 +68,138 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := logging.Setup("order", cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	exporter, err := tracing.NewExporter(cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracing.Configure("order", exporter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var repository order.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
 		repository, err = order.NewPostgresRepository(cfg.DatabaseURL)
 		if err != nil {
-			log.Println(err)
+			slog.WarnContext(ctx, "repository not ready, retrying", "error", err)
 		}
 		return
 	})
+	defer repository.Close()
+
+	bus, err := events.Open(cfg.EventBusURL, cfg.EventChannel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bus.Close()
 
-	log.Println("Listening on port 8080")
-	s := order.NewService(repository)
-	log.Fatal(order.ListendGRPC(s, cfg.AccountURL, cfg.CatalogURL, 8080))
+	relay, err := events.NewPostgresRelay(cfg.DatabaseURL, bus)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer relay.Close()
+
+	taxRates, err := repository.GetTaxRates(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shippingMethods, err := repository.GetShippingMethods(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	accountClient, err := account.NewClient(cfg.AccountURL, cfg.Client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer accountClient.Close()
+
+	catalogClient, err := catalog.NewClient(cfg.CatalogURL, cfg.Client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer catalogClient.Close()
+
+	paymentClient, err := payment.NewClient(cfg.PaymentURL, cfg.Client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer paymentClient.Close()
+
+	// The background loops end before anything deferred above is closed.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := metrics.ListenAndServe(ctx, cfg.MetricsAddr); err != nil {
+			slog.ErrorContext(ctx, "metrics not served", "error", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		relay.Run(ctx, cfg.OutboxInterval)
+	}()
+
+	checker := health.NewChecker(map[string]health.Check{
+		"postgres": repository.Ping,
+		"account":  accountClient.Health,
+		"catalog":  catalogClient.Health,
+		"payment":  paymentClient.Health,
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checker.Run(ctx, cfg.HealthInterval)
+	}()
+
+	s := order.NewService(repository,
+		order.WithCancellationHooks(order.NewPaymentCancellationHook(paymentClient)),
+		order.WithTaxCalculator(order.NewTableTaxCalculator(taxRates)),
+		order.WithShippingMethods(shippingMethods...),
+		order.WithCarriers(order.NewFakeCarrier("fake")),
+		order.WithRefunder(order.NewPaymentRefunder(paymentClient)),
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		order.TrackShipments(ctx, s, cfg.TrackingInterval)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		order.RecoverCancellations(ctx, s, cfg.CancellationRecoveryInterval)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		order.RecoverRefunds(ctx, s, cfg.RefundRecoveryInterval)
+	}()
+
+	checkout := order.NewSaga(repository, order.DefaultRetryPolicy, order.NewCheckoutSteps(s, accountClient, catalogClient, paymentClient)...)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		order.RecoverSagas(ctx, checkout, cfg.SagaRecoveryInterval)
+	}()
+
+	srv, err := order.ListendGRPC(s, checkout, catalogClient, checker, interceptor.Default(cfg.RequestTimeout), 8080)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.InfoContext(ctx, "listening", "port", 8080)
+	if err := srv.Run(ctx, cfg.ShutdownTimeout); err != nil {
+		slog.ErrorContext(ctx, "server stopped", "error", err)
+	}
+	slog.InfoContext(ctx, "shutting down")
+	stop()
 }
@@ -0,0 +1,113 @@
+package order
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lichb0rn/go-microservices/events"
+	eventpb "github.com/lichb0rn/go-microservices/events/pb"
+)
+
+type EventType string
+
+const (
+	EventCreated       EventType = "created"
+	EventStatusChanged EventType = "status_changed"
+)
+
+// watchBuffer is how many events a watcher may lag behind before it is
+// dropped.
+const watchBuffer = 64
+
+// OrderEvent reports a change of an order. From is the previous status of
+// a status change.
+type OrderEvent struct {
+	Type       EventType
+	Order      Order
+	From       Status
+	OccurredAt time.Time
+}
+
+type watcher struct {
+	accountId string
+	events    chan OrderEvent
+}
+
+// broadcaster fans the events of this instance out to its watchers.
+type broadcaster struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{watchers: map[*watcher]struct{}{}}
+}
+
+// watch returns the events of the account's orders, or of every order
+// when accountId is empty, until the context is done. A watcher too slow
+// to keep up has its channel closed, so it can reload and watch again
+// rather than silently miss events.
+func (b *broadcaster) watch(ctx context.Context, accountId string) <-chan OrderEvent {
+	w := &watcher{accountId: accountId, events: make(chan OrderEvent, watchBuffer)}
+
+	b.mu.Lock()
+	b.watchers[w] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.remove(w)
+	}()
+	return w.events
+}
+
+func (b *broadcaster) remove(w *watcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.watchers[w]; ok {
+		delete(b.watchers, w)
+		close(w.events)
+	}
+}
+
+func (b *broadcaster) publish(e OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for w := range b.watchers {
+		if w.accountId != "" && w.accountId != e.Order.AccountId {
+			continue
+		}
+		select {
+		case w.events <- e:
+		default:
+			delete(b.watchers, w)
+			close(w.events)
+		}
+	}
+}
+
+// orderPlacedEvent is the domain event published through the outbox when
+// an order is stored.
+func orderPlacedEvent(o Order) (*eventpb.Envelope, error) {
+	placed := &eventpb.OrderPlaced{
+		OrderId:       o.ID,
+		AccountId:     o.AccountId,
+		Subtotal:      o.Subtotal,
+		DiscountTotal: o.DiscountTotal,
+		TaxTotal:      o.TaxTotal,
+		ShippingCost:  o.ShippingCost,
+		TotalPrice:    o.TotalPrice,
+		Coupon:        o.Coupon,
+	}
+	for _, p := range o.Products {
+		placed.Lines = append(placed.Lines, &eventpb.OrderPlaced_Line{
+			ProductId: p.ID,
+			Quantity:  uint32(p.Quantity),
+			Price:     p.Price,
+		})
+	}
+	return events.New(events.TypeOrderPlaced, events.OrderPlacedVersion, o.ID, placed)
+}
@@ -0,0 +1,143 @@
+package order
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeRepository keeps orders in memory. The methods a test does not need
+// are left to the embedded nil Repository and panic if called.
+type fakeRepository struct {
+	Repository
+
+	mu      sync.Mutex
+	orders  map[string]Order
+	returns map[string]Return
+}
+
+func newFakeRepository(orders ...Order) *fakeRepository {
+	r := &fakeRepository{orders: map[string]Order{}, returns: map[string]Return{}}
+	for _, o := range orders {
+		r.orders[o.ID] = o
+	}
+	return r
+}
+
+func (r *fakeRepository) GetById(ctx context.Context, id string) (*Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	o.History = append([]StatusChange(nil), o.History...)
+	return &o, nil
+}
+
+// UpdateStatus is guarded on the previous status, like the Postgres one.
+func (r *fakeRepository) UpdateStatus(ctx context.Context, id string, c StatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[id]
+	if !ok || o.Status != c.From {
+		return ErrInvalidTransition
+	}
+	o.Status = c.To
+	o.History = append(o.History, c)
+	r.orders[id] = o
+	return nil
+}
+
+func (r *fakeRepository) ListCancelling(ctx context.Context, changedBefore time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []string{}
+	for id, o := range r.orders {
+		if o.Status == StatusCancelling && o.History[len(o.History)-1].CreatedAt.Before(changedBefore) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeRepository) GetReturn(ctx context.Context, id string) (*Return, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[id]
+	if !ok {
+		return nil, ErrReturnNotFound
+	}
+	return &ret, nil
+}
+
+func (r *fakeRepository) GetReturnsByOrderId(ctx context.Context, orderId string) ([]Return, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	returns := []Return{}
+	for _, ret := range r.returns {
+		if ret.OrderID == orderId {
+			returns = append(returns, ret)
+		}
+	}
+	return returns, nil
+}
+
+// UpdateReturnStatus is guarded on the previous status, like the Postgres one.
+func (r *fakeRepository) UpdateReturnStatus(ctx context.Context, id string, from, to ReturnStatus, note string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[id]
+	if !ok || ret.Status != from {
+		return ErrInvalidReturnTransition
+	}
+	ret.Status = to
+	ret.UpdatedAt = at
+	r.returns[id] = ret
+	return nil
+}
+
+func (r *fakeRepository) ListRefundingReturns(ctx context.Context, updatedBefore time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []string{}
+	for id, ret := range r.returns {
+		if ret.Status == ReturnRefunding && ret.UpdatedAt.Before(updatedBefore) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ClaimReturn is guarded on the update time, like the Postgres one.
+func (r *fakeRepository) ClaimReturn(ctx context.Context, id string, updatedAt, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret, ok := r.returns[id]
+	if !ok || ret.Status != ReturnRefunding || !ret.UpdatedAt.Equal(updatedAt) {
+		return ErrInvalidReturnTransition
+	}
+	ret.UpdatedAt = at
+	r.returns[id] = ret
+	return nil
+}
+
+func (r *fakeRepository) status(id string) Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.orders[id].Status
+}
+
+func (r *fakeRepository) returnStatus(id string) ReturnStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.returns[id].Status
+}
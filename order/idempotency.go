@@ -0,0 +1,66 @@
+package order
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+	ErrCheckoutAborted     = errors.New("the checkout of the order with this idempotency key failed")
+	errDuplicateKey        = errors.New("idempotency key already stored")
+	errDuplicateOrder      = errors.New("order already stored")
+)
+
+// IdempotencyKey identifies a client's attempt to place an order.
+// Replaying the same key with the same Fingerprint returns the order
+// created by the first attempt instead of placing a new one.
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+}
+
+// Fingerprint hashes the parts of an order request that must not change
+// between retries: the account, the requested products with quantities,
+// the coupon and the shipping.
+func Fingerprint(c Checkout) string {
+	lines := make([]string, 0, len(c.Requested))
+	for _, p := range c.Requested {
+		lines = append(lines, fmt.Sprintf("%s:%d", p.ID, p.Quantity))
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	fmt.Fprintln(h, c.AccountID)
+	for _, l := range lines {
+		fmt.Fprintln(h, l)
+	}
+	if c.Coupon != "" {
+		fmt.Fprintln(h, "coupon:"+strings.ToLower(c.Coupon))
+	}
+	if c.ShippingAddress != (Address{}) {
+		a := c.ShippingAddress
+		fmt.Fprintln(h, "address:"+strings.Join([]string{a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country}, "|"))
+	}
+	if c.ShippingMethod != "" {
+		fmt.Fprintln(h, "shipping:"+c.ShippingMethod)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayed returns the order placed by an earlier attempt with the same
+// key, or ErrCheckoutAborted if that attempt failed and cancelled it: a
+// replay must not present a declined checkout as a placed order.
+func replayed(o *Order) (*Order, error) {
+	if o.Status != StatusCancelled || len(o.History) == 0 {
+		return o, nil
+	}
+	if last := o.History[len(o.History)-1]; last.Actor == checkoutActor {
+		return nil, ErrCheckoutAborted
+	}
+	return o, nil
+}
@@ -0,0 +1,16 @@
+package order
+
+import "github.com/lichb0rn/go-microservices/metrics"
+
+var (
+	ordersPlaced = metrics.NewCounter("orders_placed_total",
+		"Orders placed.")
+	ordersPlacedAmount = metrics.NewCounter("orders_placed_amount_total",
+		"Total price of the orders placed.")
+	orderStatusChanges = metrics.NewCounter("order_status_changes_total",
+		"Orders moved to a status after being placed, by status.", "status")
+	returnsRequested = metrics.NewCounter("returns_requested_total",
+		"Returns requested.")
+	refundedAmount = metrics.NewCounter("returns_refunded_amount_total",
+		"Amount refunded for returns.")
+)
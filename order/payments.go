@@ -0,0 +1,170 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/lichb0rn/go-microservices/payment"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrPaymentDeclined       = errors.New("payment declined")
+	ErrRefundExceedsPayments = errors.New("refund exceeds the captured payments")
+)
+
+const currency = "USD"
+
+type authorizePaymentStep struct {
+	client *payment.Client
+}
+
+func (s *authorizePaymentStep) Name() string { return "authorize_payment" }
+
+func (s *authorizePaymentStep) Execute(ctx context.Context, c *Checkout) error {
+	if c.Replayed {
+		return nil
+	}
+	p, err := s.client.Authorize(ctx, c.OrderID, c.AccountID, c.Order.TotalPrice, currency)
+	switch status.Code(err) {
+	case codes.OK:
+		c.PaymentID = p.ID
+		return nil
+	case codes.FailedPrecondition, codes.InvalidArgument:
+		return Permanent(ErrPaymentDeclined)
+	}
+	return err
+}
+
+// Compensate voids every live authorization of the order rather than just
+// c.PaymentID, which is unknown if the process died during Execute. That
+// includes pending payments, whose authorization may have gone through.
+func (s *authorizePaymentStep) Compensate(ctx context.Context, c *Checkout) error {
+	if c.Replayed {
+		return nil
+	}
+	payments, err := s.client.GetByOrderId(ctx, c.OrderID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		if p.Status != payment.StatusPending && p.Status != payment.StatusAuthorized {
+			continue
+		}
+		if _, err := s.client.Void(ctx, p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type capturePaymentStep struct {
+	client  *payment.Client
+	service Service
+}
+
+func (s *capturePaymentStep) Name() string { return "capture_payment" }
+
+func (s *capturePaymentStep) Execute(ctx context.Context, c *Checkout) error {
+	if c.Replayed {
+		return nil
+	}
+	if _, err := s.client.Capture(ctx, c.PaymentID, 0); err != nil {
+		return err
+	}
+
+	o, err := s.service.UpdateStatus(ctx, c.OrderID, StatusPaid, "payment", "payment captured")
+	if errors.Is(err, ErrInvalidTransition) {
+		// Already marked paid by an earlier attempt.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Order = o
+	return nil
+}
+
+func (s *capturePaymentStep) Compensate(ctx context.Context, c *Checkout) error {
+	if c.Replayed || c.PaymentID == "" {
+		return nil
+	}
+	_, err := s.client.Refund(ctx, c.PaymentID, 0, "")
+	if status.Code(err) == codes.FailedPrecondition {
+		// Not captured, or already refunded.
+		return nil
+	}
+	return err
+}
+
+// NewPaymentCancellationHook releases the money of a cancelled order:
+// authorizations are voided and captured payments refunded in full.
+func NewPaymentCancellationHook(client *payment.Client) CancellationHook {
+	return NewCancellationHook("payment", func(ctx context.Context, o Order, reason string) error {
+		payments, err := client.GetByOrderId(ctx, o.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range payments {
+			switch p.Status {
+			case payment.StatusPending, payment.StatusAuthorized:
+				_, err = client.Void(ctx, p.ID)
+			case payment.StatusCaptured, payment.StatusPartiallyRefunded:
+				_, err = client.Refund(ctx, p.ID, 0, "")
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type paymentRefunder struct {
+	client *payment.Client
+}
+
+// NewPaymentRefunder refunds returns from the captured payments of the
+// order, oldest first. What was already refunded with the idempotency key,
+// by an attempt that failed halfway, is not refunded again.
+func NewPaymentRefunder(client *payment.Client) Refunder {
+	return &paymentRefunder{client}
+}
+
+func (r *paymentRefunder) Refund(ctx context.Context, o Order, amount float64, idempotencyKey string) error {
+	payments, err := r.client.GetByOrderId(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		amount -= p.RefundedWith(idempotencyKey)
+	}
+	amount = math.Round(amount*100) / 100
+
+	for _, p := range payments {
+		if p.RefundedWith(idempotencyKey) > 0 {
+			continue
+		}
+		if amount <= 0 {
+			break
+		}
+		if p.Status != payment.StatusCaptured && p.Status != payment.StatusPartiallyRefunded {
+			continue
+		}
+		refund := math.Min(amount, p.CapturedAmount-p.RefundedAmount)
+		if refund <= 0 {
+			continue
+		}
+		if _, err := r.client.Refund(ctx, p.ID, refund, idempotencyKey); err != nil {
+			return err
+		}
+		amount = math.Round((amount-refund)*100) / 100
+	}
+	if amount > 0 {
+		return ErrRefundExceedsPayments
+	}
+	return nil
+}
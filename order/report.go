@@ -0,0 +1,117 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidReport = errors.New("invalid report range or period")
+)
+
+type ReportPeriod string
+
+const (
+	PeriodDay   ReportPeriod = "day"
+	PeriodWeek  ReportPeriod = "week"
+	PeriodMonth ReportPeriod = "month"
+)
+
+const (
+	defaultReportTop = 10
+	maxReportTop     = 100
+)
+
+// salesStatuses are the statuses of the orders a report counts: paid and
+// not cancelled or refunded since.
+var salesStatuses = []Status{StatusPaid, StatusFulfilled, StatusShipped, StatusDelivered}
+
+// ReportFilter selects the orders placed in [From, To). Top is how many
+// products the rankings hold.
+type ReportFilter struct {
+	From   time.Time
+	To     time.Time
+	Period ReportPeriod
+	Top    int
+}
+
+// RevenuePoint is the revenue of the orders placed in the period starting
+// at Start, in UTC.
+type RevenuePoint struct {
+	Start   time.Time
+	Orders  int
+	Revenue float64
+}
+
+// ProductSales is what a product sold. Revenue is net of discounts,
+// before tax.
+type ProductSales struct {
+	ProductID string
+	Units     int
+	Revenue   float64
+}
+
+// CustomerStats splits the accounts that ordered in the range between the
+// ones ordering for the first time and the ones that had ordered before.
+type CustomerStats struct {
+	New       int
+	Returning int
+}
+
+type SalesReport struct {
+	From              time.Time
+	To                time.Time
+	Period            ReportPeriod
+	Revenue           []RevenuePoint
+	TotalRevenue      float64
+	Orders            int
+	AverageOrderValue float64
+	TopByUnits        []ProductSales
+	TopByRevenue      []ProductSales
+	Customers         CustomerStats
+}
+
+func (s *orderService) SalesReport(ctx context.Context, f ReportFilter) (*SalesReport, error) {
+	switch f.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+	default:
+		return nil, ErrInvalidReport
+	}
+	if f.From.IsZero() || !f.From.Before(f.To) {
+		return nil, ErrInvalidReport
+	}
+	if f.Top <= 0 {
+		f.Top = defaultReportTop
+	}
+	if f.Top > maxReportTop {
+		f.Top = maxReportTop
+	}
+
+	r := &SalesReport{From: f.From, To: f.To, Period: f.Period}
+
+	var err error
+	if r.Revenue, err = s.repository.RevenueByPeriod(ctx, f); err != nil {
+		return nil, err
+	}
+	for _, p := range r.Revenue {
+		r.TotalRevenue += p.Revenue
+		r.Orders += p.Orders
+	}
+	r.TotalRevenue = roundCents(r.TotalRevenue)
+	if r.Orders > 0 {
+		r.AverageOrderValue = math.Round(r.TotalRevenue/float64(r.Orders)*100) / 100
+	}
+
+	if r.TopByUnits, err = s.repository.TopProducts(ctx, f, false); err != nil {
+		return nil, err
+	}
+	if r.TopByRevenue, err = s.repository.TopProducts(ctx, f, true); err != nil {
+		return nil, err
+	}
+	if r.Customers, err = s.repository.CustomerStats(ctx, f); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -3,14 +3,76 @@ package order
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/lichb0rn/go-microservices/events"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/promotion"
+	"github.com/lichb0rn/go-microservices/shipping"
+	"github.com/lichb0rn/go-microservices/tracing"
+)
+
+var (
+	ErrNotFound         = errors.New("order not found")
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	ErrDuplicateCoupon  = errors.New("coupon code already in use")
+
+	// errPromotionExhausted is returned by Put when a promotion reached its
+	// usage limit after the order was priced. Pricing it again drops the
+	// promotion, or reports the coupon as exhausted.
+	errPromotionExhausted = errors.New("promotion usage limit reached")
 )
 
 type Repository interface {
 	Close()
-	Put(ctx context.Context, o Order) error
-	GetByAccountId(ctx context.Context, accountId string) ([]Order, error)
+	Ping(ctx context.Context) error
+	Put(ctx context.Context, o Order, key IdempotencyKey) error
+	GetById(ctx context.Context, id string) (*Order, error)
+	GetByAccountId(ctx context.Context, accountId string, filter OrderFilter) (*OrderPage, error)
+	UpdateStatus(ctx context.Context, id string, c StatusChange) error
+	// ListCancelling returns the ids of the orders cancelling since before
+	// the given time.
+	ListCancelling(ctx context.Context, changedBefore time.Time) ([]string, error)
+	GetIdempotencyKey(ctx context.Context, accountId, key string) (orderId string, fingerprint string, err error)
+	// GetPromotions returns the promotions without a code along with the
+	// one of the coupon, if it exists, in creation order.
+	GetPromotions(ctx context.Context, coupon string) ([]promotion.Promotion, error)
+	PutPromotion(ctx context.Context, p promotion.Promotion) error
+	GetTaxRates(ctx context.Context) ([]TaxRate, error)
+	GetShippingMethods(ctx context.Context) ([]shipping.Method, error)
+	// PutShipment stores the shipment unless it ships more than what is
+	// left of the order's lines.
+	PutShipment(ctx context.Context, s Shipment) error
+	GetShipmentsByOrderId(ctx context.Context, orderId string) ([]Shipment, error)
+	// GetActiveShipments returns the shipments not delivered yet.
+	GetActiveShipments(ctx context.Context) ([]Shipment, error)
+	AddTrackingEvents(ctx context.Context, shipmentId string, status ShipmentStatus, events []TrackingEvent) error
+	// PutReturn stores the return unless it returns more than what is left
+	// of the order's lines once rejected returns are set aside.
+	PutReturn(ctx context.Context, r Return) error
+	GetReturn(ctx context.Context, id string) (*Return, error)
+	GetReturnsByOrderId(ctx context.Context, orderId string) ([]Return, error)
+	// UpdateReturnStatus moves the return from one status to another,
+	// failing with ErrInvalidReturnTransition if it is no longer in from.
+	// An empty note keeps the previous one.
+	UpdateReturnStatus(ctx context.Context, id string, from, to ReturnStatus, note string, at time.Time) error
+	// ListRefundingReturns returns the ids of the returns refunding since
+	// before the given time.
+	ListRefundingReturns(ctx context.Context, updatedBefore time.Time) ([]string, error)
+	// ClaimReturn moves the update time of a refunding return to at,
+	// failing with ErrInvalidReturnTransition if it was updated since
+	// updatedAt or is no longer refunding.
+	ClaimReturn(ctx context.Context, id string, updatedAt, at time.Time) error
+	RevenueByPeriod(ctx context.Context, f ReportFilter) ([]RevenuePoint, error)
+	// TopProducts ranks the products by units sold, or by revenue.
+	TopProducts(ctx context.Context, f ReportFilter, byRevenue bool) ([]ProductSales, error)
+	CustomerStats(ctx context.Context, f ReportFilter) (CustomerStats, error)
+	SagaLog
 }
 
 type postgresRepository struct {
@@ -18,7 +80,7 @@ type postgresRepository struct {
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := tracing.OpenPostgres(url)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +89,7 @@ func NewPostgresRepository(url string) (Repository, error) {
 		return nil, err
 	}
 
+	metrics.RegisterDB("order", db)
 	return &postgresRepository{db}, nil
 }
 
@@ -34,7 +97,16 @@ func (r *postgresRepository) Close() {
 	r.db.Close()
 }
 
-func (r *postgresRepository) Put(ctx context.Context, o Order) (err error) {
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *postgresRepository) Put(ctx context.Context, o Order, key IdempotencyKey) (err error) {
+	address, err := json.Marshal(o.ShippingAddress)
+	if err != nil {
+		return
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return
@@ -42,25 +114,49 @@ func (r *postgresRepository) Put(ctx context.Context, o Order) (err error) {
 	defer func() {
 		if err != nil {
 			tx.Rollback()
+			return
 		}
 		err = tx.Commit()
 	}()
 
-	_, err = tx.ExecContext(ctx,
-		"INSERT INTO order (id, created_at, account_id, total_price) VALUES ($1, $2, $3, $4)",
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO orders (id, created_at, account_id, subtotal, discount_total, tax_total, total_price,
+		status, coupon_code, free_shipping, shipping_address, shipping_method, shipping_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (id) DO NOTHING`,
 		o.ID,
 		o.CreatedAt,
 		o.AccountId,
+		o.Subtotal,
+		o.DiscountTotal,
+		o.TaxTotal,
 		o.TotalPrice,
+		o.Status,
+		o.Coupon,
+		o.FreeShipping,
+		address,
+		o.ShippingMethod,
+		o.ShippingCost,
 	)
 
 	if err != nil {
 		return
 	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = errDuplicateOrder
+		return
+	}
 
-	stmt, _ := tx.PrepareContext(ctx, pq.CopyIn("order_product", "order_id", "product_id", "quantity"))
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("order_products", "order_id", "product_id", "quantity", "price", "discount", "tax_class", "tax_rate", "tax"))
+	if err != nil {
+		return
+	}
 	for _, p := range o.Products {
-		_, err = stmt.ExecContext(ctx, o.ID, p.ID, p.Quantity)
+		_, err = stmt.ExecContext(ctx, o.ID, p.ID, p.Quantity, p.Price, p.Discount, p.TaxClass, p.TaxRate, p.Tax)
 		if err != nil {
 			return
 		}
@@ -71,22 +167,172 @@ func (r *postgresRepository) Put(ctx context.Context, o Order) (err error) {
 	}
 	stmt.Close()
 
+	for _, c := range o.History {
+		if err = insertStatusChange(ctx, tx, o.ID, c); err != nil {
+			return
+		}
+	}
+
+	if err = redeemPromotions(ctx, tx, o); err != nil {
+		return
+	}
+
+	if key.Key != "" {
+		// A concurrent request with the same key blocks on the primary key
+		// until this transaction ends, then fails with a unique violation.
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO order_idempotency (account_id, key, fingerprint, order_id, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			o.AccountId,
+			key.Key,
+			key.Fingerprint,
+			o.ID,
+			o.CreatedAt,
+		)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			err = errDuplicateKey
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	placed, err := orderPlacedEvent(o)
+	if err != nil {
+		return
+	}
+	err = events.WriteOutbox(ctx, tx, placed)
+	return
+}
+
+func (r *postgresRepository) GetIdempotencyKey(ctx context.Context, accountId, key string) (string, string, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT order_id, fingerprint FROM order_idempotency WHERE account_id = $1 AND key = $2",
+		accountId,
+		key,
+	)
+
+	var orderId, fingerprint string
+	if err := row.Scan(&orderId, &fingerprint); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrNotFound
+		}
+		return "", "", err
+	}
+	return orderId, fingerprint, nil
+}
+
+func (r *postgresRepository) UpdateStatus(ctx context.Context, id string, c StatusChange) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Guarding on the previous status makes concurrent updates of the
+	// same order fail instead of silently skipping a transition.
+	res, err := tx.ExecContext(ctx,
+		"UPDATE orders SET status = $1 WHERE id = $2 AND status = $3",
+		c.To,
+		id,
+		c.From,
+	)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = ErrInvalidTransition
+		return
+	}
+
+	err = insertStatusChange(ctx, tx, id, c)
 	return
 }
 
-func (r *postgresRepository) GetByAccountId(ctx context.Context, accountId string) ([]Order, error) {
+func (r *postgresRepository) ListCancelling(ctx context.Context, changedBefore time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT o.id
+		FROM orders o
+		WHERE o.status = $1 AND (
+			SELECT MAX(h.created_at) FROM order_status_history h WHERE h.order_id = o.id
+		) < $2`,
+		StatusCancelling,
+		changedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func insertStatusChange(ctx context.Context, tx *sql.Tx, orderId string, c StatusChange) error {
+	from := sql.NullString{String: string(c.From), Valid: c.From != ""}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		orderId,
+		from,
+		c.To,
+		c.Actor,
+		c.Reason,
+		c.CreatedAt,
+	)
+	return err
+}
+
+func (r *postgresRepository) GetById(ctx context.Context, id string) (*Order, error) {
 	rows, err := r.db.QueryContext(ctx,
 		`SELECT
 		o.id,
 		o.created_at,
 		o.account_id,
+		o.subtotal::numeric::float8,
+		o.discount_total::numeric::float8,
+		o.tax_total::numeric::float8,
 		o.total_price::money::numeric::float8,
+		o.status,
+		o.coupon_code,
+		o.free_shipping,
+		o.shipping_address,
+		o.shipping_method,
+		o.shipping_cost::numeric::float8,
 		op.product_id,
-		op.quantity
+		op.quantity,
+		op.price::numeric::float8,
+		op.discount::numeric::float8,
+		op.tax_class,
+		op.tax_rate::float8,
+		op.tax::numeric::float8
 		FROM orders o JOIN order_products op ON (o.id = op.order_id)
-		WHERE o.account_id = $1
-		ORDER BY o.id`,
-		accountId,
+		WHERE o.id = $1`,
+		id,
 	)
 	if err != nil {
 		return nil, err
@@ -94,60 +340,1159 @@ func (r *postgresRepository) GetByAccountId(ctx context.Context, accountId strin
 
 	defer rows.Close()
 
-	orders := []Order{}
-	order := &Order{}
-	lastOrder := &Order{}
-	orderedProduct := &OrderedProduct{}
-	prodcuts := []OrderedProduct{}
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orders) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err := r.loadHistory(ctx, orders); err != nil {
+		return nil, err
+	}
+	if err := r.loadDiscounts(ctx, orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+func (r *postgresRepository) GetByAccountId(ctx context.Context, accountId string, f OrderFilter) (*OrderPage, error) {
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{"account_id = " + arg(accountId)}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(f.CreatedAfter))
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(f.CreatedBefore))
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, 0, len(f.Statuses))
+		for _, s := range f.Statuses {
+			statuses = append(statuses, string(s))
+		}
+		where = append(where, "status = ANY("+arg(pq.Array(statuses))+")")
+	}
+	if f.MinTotal != nil {
+		where = append(where, "total_price >= "+arg(*f.MinTotal)+"::numeric::money")
+	}
+	if f.MaxTotal != nil {
+		where = append(where, "total_price <= "+arg(*f.MaxTotal)+"::numeric::money")
+	}
+
+	direction, comparison := "ASC", ">"
+	if f.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	// Keyset pagination: continue strictly after the cursor order
+	// in (created_at, id) order, which orders_account_id_created_at_idx covers.
+	if f.After != "" {
+		where = append(where, fmt.Sprintf(
+			"(created_at, id) %s (SELECT created_at, id FROM orders WHERE id = %s)",
+			comparison,
+			arg(f.After),
+		))
+	}
+
+	// The page is cut in the CTE, before the join multiplies the rows by
+	// products. One extra order tells whether there is a next page.
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(
+		`WITH page AS (
+			SELECT id, created_at, account_id, subtotal, discount_total, tax_total, total_price,
+			status, coupon_code, free_shipping, shipping_address, shipping_method, shipping_cost
+			FROM orders
+			WHERE %s
+			ORDER BY created_at %s, id %s
+			LIMIT %s
+		)
+		SELECT
+		o.id,
+		o.created_at,
+		o.account_id,
+		o.subtotal::numeric::float8,
+		o.discount_total::numeric::float8,
+		o.tax_total::numeric::float8,
+		o.total_price::money::numeric::float8,
+		o.status,
+		o.coupon_code,
+		o.free_shipping,
+		o.shipping_address,
+		o.shipping_method,
+		o.shipping_cost::numeric::float8,
+		op.product_id,
+		op.quantity,
+		op.price::numeric::float8,
+		op.discount::numeric::float8,
+		op.tax_class,
+		op.tax_rate::float8,
+		op.tax::numeric::float8
+		FROM page o JOIN order_products op ON (o.id = op.order_id)
+		ORDER BY o.created_at %s, o.id %s`,
+		strings.Join(where, " AND "),
+		direction, direction,
+		arg(f.Take+1),
+		direction, direction,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{Orders: orders}
+	if uint64(len(orders)) > f.Take {
+		page.Orders = orders[:f.Take]
+		page.NextCursor = page.Orders[len(page.Orders)-1].ID
+	}
+
+	if err := r.loadHistory(ctx, page.Orders); err != nil {
+		return nil, err
+	}
+	if err := r.loadDiscounts(ctx, page.Orders); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// loadHistory attaches the status history to the given orders
+// with a single query.
+func (r *postgresRepository) loadHistory(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(orders))
+	ids := make([]string, 0, len(orders))
+	for i, o := range orders {
+		index[o.ID] = i
+		ids = append(ids, o.ID)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, from_status, to_status, actor, reason, created_at
+		FROM order_status_history
+		WHERE order_id = ANY($1)
+		ORDER BY id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderId string
+		var from sql.NullString
+		c := StatusChange{}
+		if err := rows.Scan(&orderId, &from, &c.To, &c.Actor, &c.Reason, &c.CreatedAt); err != nil {
+			return err
+		}
+		c.From = Status(from.String)
+
+		i := index[orderId]
+		orders[i].History = append(orders[i].History, c)
+	}
+
+	return rows.Err()
+}
+
+// loadDiscounts attaches the applied discounts to the given orders
+// with a single query.
+func (r *postgresRepository) loadDiscounts(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(orders))
+	ids := make([]string, 0, len(orders))
+	for i, o := range orders {
+		index[o.ID] = i
+		ids = append(ids, o.ID)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, promotion_id, code, kind, product_id, amount::numeric::float8
+		FROM order_discounts
+		WHERE order_id = ANY($1)
+		ORDER BY id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
 
 	for rows.Next() {
+		var orderId string
+		var productId sql.NullString
+		d := promotion.Discount{}
+		if err := rows.Scan(&orderId, &d.PromotionID, &d.Code, &d.Kind, &productId, &d.Amount); err != nil {
+			return err
+		}
+		d.ProductID = productId.String
+
+		i := index[orderId]
+		orders[i].Discounts = append(orders[i].Discounts, d)
+	}
+
+	return rows.Err()
+}
+
+// redeemPromotions records the discounts of the order and counts one use of
+// each promotion behind them, within the limit.
+func redeemPromotions(ctx context.Context, tx *sql.Tx, o Order) error {
+	redeemed := map[string]bool{}
+	for _, d := range o.Discounts {
+		if !redeemed[d.PromotionID] {
+			res, err := tx.ExecContext(ctx,
+				`UPDATE promotions SET usage_count = usage_count + 1
+				WHERE id = $1 AND (usage_limit = 0 OR usage_count < usage_limit)`,
+				d.PromotionID,
+			)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errPromotionExhausted
+			}
+			redeemed[d.PromotionID] = true
+		}
 
+		var productId sql.NullString
+		if d.ProductID != "" {
+			productId = sql.NullString{String: d.ProductID, Valid: true}
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO order_discounts (order_id, promotion_id, code, kind, product_id, amount)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			o.ID,
+			d.PromotionID,
+			d.Code,
+			d.Kind,
+			productId,
+			d.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *postgresRepository) GetPromotions(ctx context.Context, coupon string) ([]promotion.Promotion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT
+		id,
+		name,
+		kind,
+		COALESCE(code, ''),
+		percent::float8,
+		amount::numeric::float8,
+		buy_quantity,
+		get_quantity,
+		product_ids,
+		min_subtotal::numeric::float8,
+		starts_at,
+		ends_at,
+		usage_limit,
+		usage_count
+		FROM promotions
+		WHERE code IS NULL OR ($1 <> '' AND LOWER(code) = LOWER($1))
+		ORDER BY created_at, id`,
+		coupon,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	promotions := []promotion.Promotion{}
+	for rows.Next() {
+		p := promotion.Promotion{}
+		var startsAt, endsAt sql.NullTime
 		if err := rows.Scan(
-			&order.ID,
-			&order.CreatedAt,
-			&order.AccountId,
-			&order.CreatedAt,
-			&order.TotalPrice,
-			&orderedProduct.ID,
-			&orderedProduct.Quantity,
+			&p.ID,
+			&p.Name,
+			&p.Kind,
+			&p.Code,
+			&p.Percent,
+			&p.Amount,
+			&p.BuyQuantity,
+			&p.GetQuantity,
+			pq.Array(&p.ProductIDs),
+			&p.MinSubtotal,
+			&startsAt,
+			&endsAt,
+			&p.UsageLimit,
+			&p.UsageCount,
 		); err != nil {
 			return nil, err
 		}
+		p.StartsAt = startsAt.Time
+		p.EndsAt = endsAt.Time
+		promotions = append(promotions, p)
+	}
 
-		if lastOrder.ID != "" && lastOrder.ID != order.ID {
-			newOrder := Order{
-				ID:         lastOrder.ID,
-				CreatedAt:  lastOrder.CreatedAt,
-				AccountId:  lastOrder.AccountId,
-				TotalPrice: lastOrder.TotalPrice,
-				Products:   lastOrder.Products,
-			}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-			orders = append(orders, newOrder)
-			prodcuts = []OrderedProduct{}
-		}
-		prodcuts = append(prodcuts, OrderedProduct{
-			ID:       orderedProduct.ID,
-			Quantity: orderedProduct.Quantity,
-		})
-		*lastOrder = *order
+	return promotions, nil
+}
+
+func (r *postgresRepository) PutPromotion(ctx context.Context, p promotion.Promotion) error {
+	var code sql.NullString
+	if p.Code != "" {
+		code = sql.NullString{String: p.Code, Valid: true}
+	}
+	var startsAt, endsAt sql.NullTime
+	if !p.StartsAt.IsZero() {
+		startsAt = sql.NullTime{Time: p.StartsAt, Valid: true}
 	}
+	if !p.EndsAt.IsZero() {
+		endsAt = sql.NullTime{Time: p.EndsAt, Valid: true}
+	}
+	productIds := p.ProductIDs
+	if productIds == nil {
+		productIds = []string{}
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO promotions (id, name, kind, code, percent, amount, buy_quantity, get_quantity,
+		product_ids, min_subtotal, starts_at, ends_at, usage_limit, usage_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		p.ID,
+		p.Name,
+		p.Kind,
+		code,
+		p.Percent,
+		p.Amount,
+		p.BuyQuantity,
+		p.GetQuantity,
+		pq.Array(productIds),
+		p.MinSubtotal,
+		startsAt,
+		endsAt,
+		p.UsageLimit,
+		p.UsageCount,
+		time.Now().UTC(),
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateCoupon
+	}
+	return err
+}
+
+func (r *postgresRepository) GetTaxRates(ctx context.Context) ([]TaxRate, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT country, region, tax_class, rate::float8 FROM tax_rates")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-	if lastOrder != nil {
-		newOrder := Order{
-			ID:         lastOrder.ID,
-			CreatedAt:  lastOrder.CreatedAt,
-			AccountId:  lastOrder.AccountId,
-			TotalPrice: lastOrder.TotalPrice,
-			Products:   lastOrder.Products,
+	rates := []TaxRate{}
+	for rows.Next() {
+		t := TaxRate{}
+		if err := rows.Scan(&t.Country, &t.Region, &t.TaxClass, &t.Rate); err != nil {
+			return nil, err
 		}
+		rates = append(rates, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+func (r *postgresRepository) GetShippingMethods(ctx context.Context) ([]shipping.Method, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, kind, rate::numeric::float8, per_kg::numeric::float8, threshold::numeric::float8, countries
+		FROM shipping_methods
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-		orders = append(orders, newOrder)
+	methods := []shipping.Method{}
+	for rows.Next() {
+		m := shipping.Method{}
+		if err := rows.Scan(&m.ID, &m.Name, &m.Kind, &m.Rate, &m.PerKg, &m.Threshold, pq.Array(&m.Countries)); err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return orders, nil
+	return methods, nil
+}
+
+func (r *postgresRepository) PutShipment(ctx context.Context, s Shipment) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Locking the order serializes concurrent shipments of it, so the
+	// quantities left to ship cannot be counted twice.
+	_, err = tx.ExecContext(ctx, "SELECT id FROM orders WHERE id = $1 FOR UPDATE", s.OrderID)
+	if err != nil {
+		return
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT op.product_id, op.quantity - COALESCE((
+			SELECT SUM(sl.quantity)
+			FROM shipment_lines sl
+			JOIN shipments s ON s.id = sl.shipment_id
+			WHERE s.order_id = op.order_id AND sl.product_id = op.product_id
+		), 0)
+		FROM order_products op
+		WHERE op.order_id = $1`,
+		s.OrderID,
+	)
+	if err != nil {
+		return
+	}
+	left := map[string]int{}
+	for rows.Next() {
+		var productId string
+		var quantity int
+		if err = rows.Scan(&productId, &quantity); err != nil {
+			rows.Close()
+			return
+		}
+		left[productId] = quantity
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+	for _, l := range s.Lines {
+		if l.Quantity > left[l.ProductID] {
+			err = ErrOverShipped
+			return
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shipments (id, order_id, carrier, tracking_number, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		s.ID,
+		s.OrderID,
+		s.Carrier,
+		s.TrackingNumber,
+		s.Status,
+		s.CreatedAt,
+		s.UpdatedAt,
+	)
+	if err != nil {
+		return
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("shipment_lines", "shipment_id", "product_id", "quantity"))
+	if err != nil {
+		return
+	}
+	for _, l := range s.Lines {
+		if _, err = stmt.ExecContext(ctx, s.ID, l.ProductID, l.Quantity); err != nil {
+			stmt.Close()
+			return
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return
+	}
+	err = stmt.Close()
+	return
+}
+
+func (r *postgresRepository) GetShipmentsByOrderId(ctx context.Context, orderId string) ([]Shipment, error) {
+	return r.getShipments(ctx, "order_id = $1", orderId)
+}
+
+func (r *postgresRepository) GetActiveShipments(ctx context.Context) ([]Shipment, error) {
+	return r.getShipments(ctx, "status <> $1", ShipmentDelivered)
+}
+
+// getShipments loads the shipments matching the condition along with
+// their lines and events.
+func (r *postgresRepository) getShipments(ctx context.Context, condition string, args ...interface{}) ([]Shipment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, order_id, carrier, tracking_number, status, created_at, updated_at
+		FROM shipments
+		WHERE `+condition+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shipments := []Shipment{}
+	index := map[string]int{}
+	ids := []string{}
+	for rows.Next() {
+		s := Shipment{Lines: []ShipmentLine{}, Events: []TrackingEvent{}}
+		if err := rows.Scan(&s.ID, &s.OrderID, &s.Carrier, &s.TrackingNumber, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[s.ID] = len(shipments)
+		ids = append(ids, s.ID)
+		shipments = append(shipments, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return shipments, nil
+	}
+
+	lines, err := r.db.QueryContext(ctx,
+		`SELECT shipment_id, product_id, quantity
+		FROM shipment_lines
+		WHERE shipment_id = ANY($1)
+		ORDER BY shipment_id, product_id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer lines.Close()
+
+	for lines.Next() {
+		var shipmentId string
+		l := ShipmentLine{}
+		if err := lines.Scan(&shipmentId, &l.ProductID, &l.Quantity); err != nil {
+			return nil, err
+		}
+		i := index[shipmentId]
+		shipments[i].Lines = append(shipments[i].Lines, l)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+
+	events, err := r.db.QueryContext(ctx,
+		`SELECT shipment_id, status, description, location, occurred_at
+		FROM shipment_events
+		WHERE shipment_id = ANY($1)
+		ORDER BY id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer events.Close()
+
+	for events.Next() {
+		var shipmentId string
+		e := TrackingEvent{}
+		if err := events.Scan(&shipmentId, &e.Status, &e.Description, &e.Location, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		i := index[shipmentId]
+		shipments[i].Events = append(shipments[i].Events, e)
+	}
+
+	return shipments, events.Err()
+}
+
+func (r *postgresRepository) AddTrackingEvents(ctx context.Context, shipmentId string, status ShipmentStatus, events []TrackingEvent) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE shipments SET status = $1, updated_at = $2 WHERE id = $3",
+		status,
+		time.Now().UTC(),
+		shipmentId,
+	)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = ErrShipmentNotFound
+		return
+	}
+
+	for _, e := range events {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO shipment_events (shipment_id, status, description, location, occurred_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			shipmentId,
+			e.Status,
+			e.Description,
+			e.Location,
+			e.OccurredAt,
+		)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *postgresRepository) PutReturn(ctx context.Context, ret Return) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.ExecContext(ctx, "SELECT id FROM orders WHERE id = $1 FOR UPDATE", ret.OrderID)
+	if err != nil {
+		return
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT op.product_id, op.quantity - COALESCE((
+			SELECT SUM(rl.quantity)
+			FROM return_lines rl
+			JOIN returns rt ON rt.id = rl.return_id
+			WHERE rt.order_id = op.order_id AND rl.product_id = op.product_id AND rt.status <> $2
+		), 0)
+		FROM order_products op
+		WHERE op.order_id = $1`,
+		ret.OrderID,
+		ReturnRejected,
+	)
+	if err != nil {
+		return
+	}
+	left := map[string]int{}
+	for rows.Next() {
+		var productId string
+		var quantity int
+		if err = rows.Scan(&productId, &quantity); err != nil {
+			rows.Close()
+			return
+		}
+		left[productId] = quantity
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+	for _, l := range ret.Lines {
+		if l.Quantity > left[l.ProductID] {
+			err = ErrOverReturned
+			return
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO returns (id, order_id, account_id, status, reason, note, refund_amount, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		ret.ID,
+		ret.OrderID,
+		ret.AccountID,
+		ret.Status,
+		ret.Reason,
+		ret.Note,
+		ret.RefundAmount,
+		ret.CreatedAt,
+		ret.UpdatedAt,
+	)
+	if err != nil {
+		return
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("return_lines", "return_id", "product_id", "quantity", "amount"))
+	if err != nil {
+		return
+	}
+	for _, l := range ret.Lines {
+		if _, err = stmt.ExecContext(ctx, ret.ID, l.ProductID, l.Quantity, l.Amount); err != nil {
+			stmt.Close()
+			return
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return
+	}
+	err = stmt.Close()
+	return
+}
+
+func (r *postgresRepository) GetReturn(ctx context.Context, id string) (*Return, error) {
+	returns, err := r.getReturns(ctx, "id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return nil, ErrReturnNotFound
+	}
+	return &returns[0], nil
+}
+
+func (r *postgresRepository) GetReturnsByOrderId(ctx context.Context, orderId string) ([]Return, error) {
+	return r.getReturns(ctx, "order_id = $1", orderId)
+}
+
+// getReturns loads the returns matching the condition along with their
+// lines.
+func (r *postgresRepository) getReturns(ctx context.Context, condition string, args ...interface{}) ([]Return, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, order_id, account_id, status, reason, note, refund_amount::numeric::float8, created_at, updated_at
+		FROM returns
+		WHERE `+condition+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	returns := []Return{}
+	index := map[string]int{}
+	ids := []string{}
+	for rows.Next() {
+		ret := Return{Lines: []ReturnLine{}}
+		if err := rows.Scan(&ret.ID, &ret.OrderID, &ret.AccountID, &ret.Status, &ret.Reason, &ret.Note, &ret.RefundAmount, &ret.CreatedAt, &ret.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[ret.ID] = len(returns)
+		ids = append(ids, ret.ID)
+		returns = append(returns, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	lines, err := r.db.QueryContext(ctx,
+		`SELECT return_id, product_id, quantity, amount::numeric::float8
+		FROM return_lines
+		WHERE return_id = ANY($1)
+		ORDER BY return_id, product_id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer lines.Close()
+
+	for lines.Next() {
+		var returnId string
+		l := ReturnLine{}
+		if err := lines.Scan(&returnId, &l.ProductID, &l.Quantity, &l.Amount); err != nil {
+			return nil, err
+		}
+		i := index[returnId]
+		returns[i].Lines = append(returns[i].Lines, l)
+	}
+
+	return returns, lines.Err()
+}
+
+func (r *postgresRepository) UpdateReturnStatus(ctx context.Context, id string, from, to ReturnStatus, note string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE returns SET status = $1, note = COALESCE(NULLIF($2, ''), note), updated_at = $3
+		WHERE id = $4 AND status = $5`,
+		to,
+		note,
+		at,
+		id,
+		from,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidReturnTransition
+	}
+	return nil
+}
+
+func (r *postgresRepository) ListRefundingReturns(ctx context.Context, updatedBefore time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id FROM returns WHERE status = $1 AND updated_at < $2 ORDER BY updated_at",
+		ReturnRefunding,
+		updatedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *postgresRepository) ClaimReturn(ctx context.Context, id string, updatedAt, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE returns SET updated_at = $1
+		WHERE id = $2 AND updated_at = $3 AND status = $4`,
+		at,
+		id,
+		updatedAt,
+		ReturnRefunding,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidReturnTransition
+	}
+	return nil
+}
+
+func (r *postgresRepository) RevenueByPeriod(ctx context.Context, f ReportFilter) ([]RevenuePoint, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT date_trunc($1, created_at AT TIME ZONE 'UTC') AS period, COUNT(*), SUM(total_price)::numeric::float8
+		FROM orders
+		WHERE created_at >= $2 AND created_at < $3 AND status = ANY($4)
+		GROUP BY period
+		ORDER BY period`,
+		f.Period,
+		f.From,
+		f.To,
+		pq.Array(salesStatuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	points := []RevenuePoint{}
+	for rows.Next() {
+		p := RevenuePoint{}
+		if err := rows.Scan(&p.Start, &p.Orders, &p.Revenue); err != nil {
+			return nil, err
+		}
+		// date_trunc of a timestamp without time zone comes back as UTC
+		// wall time.
+		p.Start = time.Date(p.Start.Year(), p.Start.Month(), p.Start.Day(), 0, 0, 0, 0, time.UTC)
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+func (r *postgresRepository) TopProducts(ctx context.Context, f ReportFilter, byRevenue bool) ([]ProductSales, error) {
+	order := "units DESC, revenue DESC"
+	if byRevenue {
+		order = "revenue DESC, units DESC"
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT op.product_id, SUM(op.quantity) AS units, SUM(op.price * op.quantity - op.discount)::numeric::float8 AS revenue
+		FROM order_products op
+		JOIN orders o ON o.id = op.order_id
+		WHERE o.created_at >= $1 AND o.created_at < $2 AND o.status = ANY($3)
+		GROUP BY op.product_id
+		ORDER BY `+order+`, op.product_id
+		LIMIT $4`,
+		f.From,
+		f.To,
+		pq.Array(salesStatuses),
+		f.Top,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	products := []ProductSales{}
+	for rows.Next() {
+		p := ProductSales{}
+		if err := rows.Scan(&p.ProductID, &p.Units, &p.Revenue); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+func (r *postgresRepository) CustomerStats(ctx context.Context, f ReportFilter) (CustomerStats, error) {
+	// Accounts with an order in the range, split on whether their first
+	// order ever falls in it.
+	row := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FILTER (WHERE first_order >= $1), COUNT(*) FILTER (WHERE first_order < $1)
+		FROM (
+			SELECT account_id, MIN(created_at) AS first_order
+			FROM orders
+			WHERE created_at < $2 AND status = ANY($3)
+			GROUP BY account_id
+			HAVING MAX(created_at) >= $1
+		) customers`,
+		f.From,
+		f.To,
+		pq.Array(salesStatuses),
+	)
+
+	stats := CustomerStats{}
+	err := row.Scan(&stats.New, &stats.Returning)
+	return stats, err
+}
+
+// scanOrders folds rows of the orders/order_products join into orders.
+// Rows of one order must be adjacent, which ordering by the order id guarantees.
+func scanOrders(rows *sql.Rows) ([]Order, error) {
+	orders := []Order{}
+	order := Order{}
+	orderedProduct := OrderedProduct{}
+	var address []byte
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&order.ID,
+			&order.CreatedAt,
+			&order.AccountId,
+			&order.Subtotal,
+			&order.DiscountTotal,
+			&order.TaxTotal,
+			&order.TotalPrice,
+			&order.Status,
+			&order.Coupon,
+			&order.FreeShipping,
+			&address,
+			&order.ShippingMethod,
+			&order.ShippingCost,
+			&orderedProduct.ID,
+			&orderedProduct.Quantity,
+			&orderedProduct.Price,
+			&orderedProduct.Discount,
+			&orderedProduct.TaxClass,
+			&orderedProduct.TaxRate,
+			&orderedProduct.Tax,
+		); err != nil {
+			return nil, err
+		}
+
+		if len(orders) == 0 || orders[len(orders)-1].ID != order.ID {
+			o := Order{
+				ID:             order.ID,
+				CreatedAt:      order.CreatedAt,
+				AccountId:      order.AccountId,
+				Subtotal:       order.Subtotal,
+				DiscountTotal:  order.DiscountTotal,
+				TaxTotal:       order.TaxTotal,
+				TotalPrice:     order.TotalPrice,
+				Status:         order.Status,
+				Products:       []OrderedProduct{},
+				Coupon:         order.Coupon,
+				FreeShipping:   order.FreeShipping,
+				ShippingMethod: order.ShippingMethod,
+				ShippingCost:   order.ShippingCost,
+			}
+			if err := json.Unmarshal(address, &o.ShippingAddress); err != nil {
+				return nil, err
+			}
+			orders = append(orders, o)
+		}
+
+		last := &orders[len(orders)-1]
+		last.Products = append(last.Products, orderedProduct)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *postgresRepository) CreateSaga(ctx context.Context, rec SagaRecord) error {
+	payload, err := json.Marshal(rec.Checkout)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO order_sagas (id, order_id, state, step, payload, last_error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		rec.ID,
+		rec.Checkout.OrderID,
+		rec.State,
+		rec.Step,
+		payload,
+		rec.LastError,
+		rec.CreatedAt,
+		rec.UpdatedAt,
+	)
+	return err
+}
+
+func (r *postgresRepository) SaveSaga(ctx context.Context, rec SagaRecord) error {
+	payload, err := json.Marshal(rec.Checkout)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"UPDATE order_sagas SET state = $1, step = $2, payload = $3, last_error = $4, updated_at = $5 WHERE id = $6",
+		rec.State,
+		rec.Step,
+		payload,
+		rec.LastError,
+		rec.UpdatedAt,
+		rec.ID,
+	)
+	return err
+}
+
+func (r *postgresRepository) LogSagaAttempt(ctx context.Context, sagaId, step, action string, attempt int, stepErr error) error {
+	message := ""
+	if stepErr != nil {
+		message = stepErr.Error()
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO order_saga_attempts (saga_id, step, action, attempt, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		sagaId,
+		step,
+		action,
+		attempt,
+		message,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+func (r *postgresRepository) ListPendingSagas(ctx context.Context, updatedBefore time.Time) ([]SagaRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, state, step, payload, last_error, created_at, updated_at
+		FROM order_sagas
+		WHERE state IN ('running', 'compensating') AND updated_at < $1
+		ORDER BY created_at`,
+		updatedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := []SagaRecord{}
+	for rows.Next() {
+		rec := SagaRecord{}
+		var payload []byte
+		if err := rows.Scan(&rec.ID, &rec.State, &rec.Step, &payload, &rec.LastError, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &rec.Checkout); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (r *postgresRepository) ClaimSaga(ctx context.Context, id string, updatedAt, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE order_sagas SET updated_at = $1
+		WHERE id = $2 AND updated_at = $3 AND state IN ('running', 'compensating')`,
+		at,
+		id,
+		updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSagaClaimed
+	}
+	return nil
 }
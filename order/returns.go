@@ -0,0 +1,359 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+var (
+	ErrReturnNotFound          = errors.New("return not found")
+	ErrInvalidReturn           = errors.New("invalid return")
+	ErrNotReturnable           = errors.New("order cannot be returned in its current status")
+	ErrOverReturned            = errors.New("return exceeds the quantities left to return")
+	ErrInvalidReturnTransition = errors.New("invalid return status transition")
+	ErrRefundUnavailable       = errors.New("no refunder configured")
+)
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+	ReturnRefunding ReturnStatus = "refunding"
+	ReturnRefunded  ReturnStatus = "refunded"
+)
+
+// returnRefundStaleAfter is how long a return stays refunding before its
+// refund may be issued again, leaving a RefundReturn in flight the time to
+// finish.
+const returnRefundStaleAfter = 2 * time.Minute
+
+// returnTransitions lists every status a return may move to from a given
+// status. Rejected and refunded are terminal. A return is refunding while
+// its refund is being issued, and goes back to received if that fails.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnRefunding},
+	ReturnRefunding: {ReturnRefunded, ReturnReceived},
+	ReturnRejected:  {},
+	ReturnRefunded:  {},
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Return is a customer's request to send back some of the lines of an
+// order. RefundAmount is fixed when it is requested, from the prices
+// snapshotted on the order.
+type Return struct {
+	ID           string
+	OrderID      string
+	AccountID    string
+	Status       ReturnStatus
+	Reason       string
+	Note         string
+	Lines        []ReturnLine
+	RefundAmount float64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ReturnLine is a returned quantity of a product and the amount refunded
+// for it.
+type ReturnLine struct {
+	ProductID string
+	Quantity  int
+	Amount    float64
+}
+
+// Refunder gives the money of returned goods back to the customer. Refunds
+// are keyed by the idempotency key, so that retrying one with the same key
+// does not refund twice.
+type Refunder interface {
+	Refund(ctx context.Context, o Order, amount float64, idempotencyKey string) error
+}
+
+// WithRefunder sets how returns are refunded. Without one, RefundReturn
+// fails with ErrRefundUnavailable.
+func WithRefunder(r Refunder) ServiceOption {
+	return func(s *orderService) {
+		s.refunder = r
+	}
+}
+
+func (s *orderService) RequestReturn(ctx context.Context, orderId, accountId, reason string, lines []ReturnLine) (*Return, error) {
+	o, err := s.repository.GetById(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if o.AccountId != accountId {
+		return nil, ErrNotOwner
+	}
+	if o.Status != StatusShipped && o.Status != StatusDelivered {
+		return nil, ErrNotReturnable
+	}
+
+	returns, err := s.repository.GetReturnsByOrderId(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	lines, err = priceReturnLines(*o, returns, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	r := Return{
+		ID:        ksuid.New().String(),
+		OrderID:   orderId,
+		AccountID: accountId,
+		Status:    ReturnRequested,
+		Reason:    reason,
+		Lines:     lines,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, l := range lines {
+		r.RefundAmount += l.Amount
+	}
+	r.RefundAmount = math.Round(r.RefundAmount*100) / 100
+
+	if err := s.repository.PutReturn(ctx, r); err != nil {
+		return nil, err
+	}
+	returnsRequested.Inc()
+	return &r, nil
+}
+
+// priceReturnLines checks the lines against what is left to return of the
+// order and prices them. A unit is refunded at its snapshotted price, less
+// its share of the line's discount plus its share of the tax. Shipping is
+// not refunded.
+func priceReturnLines(o Order, returns []Return, lines []ReturnLine) ([]ReturnLine, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: no lines", ErrInvalidReturn)
+	}
+
+	ordered := map[string]OrderedProduct{}
+	for _, p := range o.Products {
+		ordered[p.ID] = p
+	}
+	returned := returnedQuantities(returns)
+
+	priced := make([]ReturnLine, 0, len(lines))
+	seen := map[string]bool{}
+	for _, l := range lines {
+		p, ok := ordered[l.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %s is not in the order", ErrInvalidReturn, l.ProductID)
+		}
+		if l.Quantity <= 0 || seen[l.ProductID] {
+			return nil, fmt.Errorf("%w: invalid line for product %s", ErrInvalidReturn, l.ProductID)
+		}
+		seen[l.ProductID] = true
+		if returned[l.ProductID]+l.Quantity > p.Quantity {
+			return nil, ErrOverReturned
+		}
+
+		unit := (p.Price*float64(p.Quantity) - p.Discount + p.Tax) / float64(p.Quantity)
+		l.Amount = math.Round(unit*float64(l.Quantity)*100) / 100
+		priced = append(priced, l)
+	}
+	return priced, nil
+}
+
+// returnedQuantities sums the quantities of every product over the returns
+// that were not rejected.
+func returnedQuantities(returns []Return) map[string]int {
+	returned := map[string]int{}
+	for _, r := range returns {
+		if r.Status == ReturnRejected {
+			continue
+		}
+		for _, l := range r.Lines {
+			returned[l.ProductID] += l.Quantity
+		}
+	}
+	return returned
+}
+
+func (s *orderService) ApproveReturn(ctx context.Context, id, note string) (*Return, error) {
+	return s.moveReturn(ctx, id, ReturnApproved, note)
+}
+
+func (s *orderService) RejectReturn(ctx context.Context, id, note string) (*Return, error) {
+	return s.moveReturn(ctx, id, ReturnRejected, note)
+}
+
+func (s *orderService) ReceiveReturn(ctx context.Context, id string) (*Return, error) {
+	return s.moveReturn(ctx, id, ReturnReceived, "")
+}
+
+// RefundReturn gives the refund amount back and, once every line of the
+// order was refunded, marks the order refunded.
+//
+// The return is moved to refunding before the refund is issued, so that
+// concurrent calls cannot both issue it: all but one fail with
+// ErrInvalidReturnTransition. A failed refund moves it back to received,
+// to be retried. A return left refunding for a while, by a call that died
+// or could not store the outcome, is claimed and refunded again. The refund
+// is keyed on the return, so a retry after a refund that went through, in
+// full or halfway, only issues the rest.
+func (s *orderService) RefundReturn(ctx context.Context, id string) (*Return, error) {
+	if s.refunder == nil {
+		return nil, ErrRefundUnavailable
+	}
+
+	r, err := s.repository.GetReturn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status == ReturnRefunding {
+		err = s.claimRefund(ctx, r)
+	} else {
+		r, err = s.moveReturn(ctx, id, ReturnRefunding, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := s.repository.GetById(ctx, r.OrderID)
+	if err == nil {
+		err = s.refunder.Refund(ctx, *o, r.RefundAmount, r.ID)
+	}
+	if err != nil {
+		if _, moveErr := s.moveReturn(context.WithoutCancel(ctx), id, ReturnReceived, ""); moveErr != nil {
+			return nil, errors.Join(err, moveErr)
+		}
+		return nil, err
+	}
+	refundedAmount.Add(r.RefundAmount)
+
+	r, err = s.moveReturn(context.WithoutCancel(ctx), id, ReturnRefunded, "")
+	if err != nil {
+		return nil, err
+	}
+
+	returns, err := s.repository.GetReturnsByOrderId(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if fullyRefunded(*o, returns) && o.Status.CanTransitionTo(StatusRefunded) {
+		_, err := s.UpdateStatus(ctx, o.ID, StatusRefunded, "returns", "all items returned")
+		if err != nil && !errors.Is(err, ErrInvalidTransition) {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// claimRefund takes over the refund of a return left refunding for a while.
+// Claiming it moves its update time forward, so that only one caller does.
+func (s *orderService) claimRefund(ctx context.Context, r *Return) error {
+	if time.Since(r.UpdatedAt) < returnRefundStaleAfter {
+		return ErrInvalidReturnTransition
+	}
+	now := time.Now().UTC()
+	if err := s.repository.ClaimReturn(ctx, r.ID, r.UpdatedAt, now); err != nil {
+		return err
+	}
+	r.UpdatedAt = now
+	return nil
+}
+
+func (s *orderService) ResumeRefunds(ctx context.Context) error {
+	ids, err := s.repository.ListRefundingReturns(ctx, time.Now().UTC().Add(-returnRefundStaleAfter))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err := s.RefundReturn(ctx, id)
+		if err != nil && !errors.Is(err, ErrInvalidReturnTransition) {
+			slog.ErrorContext(ctx, "return refund not resumed", "return", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// RecoverRefunds completes the refunds of returns left refunding every
+// interval until the context is done.
+func RecoverRefunds(ctx context.Context, s Service, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ResumeRefunds(ctx); err != nil {
+				slog.ErrorContext(ctx, "return refunds not resumed", "error", err)
+			}
+		}
+	}
+}
+
+// fullyRefunded tells whether every unit of the order was refunded.
+func fullyRefunded(o Order, returns []Return) bool {
+	refunded := map[string]int{}
+	for _, r := range returns {
+		if r.Status != ReturnRefunded {
+			continue
+		}
+		for _, l := range r.Lines {
+			refunded[l.ProductID] += l.Quantity
+		}
+	}
+	for _, p := range o.Products {
+		if refunded[p.ID] < p.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *orderService) moveReturn(ctx context.Context, id string, to ReturnStatus, note string) (*Return, error) {
+	r, err := s.repository.GetReturn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Status.CanTransitionTo(to) {
+		return nil, ErrInvalidReturnTransition
+	}
+
+	now := time.Now().UTC()
+	if err := s.repository.UpdateReturnStatus(ctx, id, r.Status, to, note, now); err != nil {
+		return nil, err
+	}
+
+	r.Status = to
+	if note != "" {
+		r.Note = note
+	}
+	r.UpdatedAt = now
+	return r, nil
+}
+
+func (s *orderService) GetReturn(ctx context.Context, id string) (*Return, error) {
+	return s.repository.GetReturn(ctx, id)
+}
+
+func (s *orderService) GetReturns(ctx context.Context, orderId string) ([]Return, error) {
+	return s.repository.GetReturnsByOrderId(ctx, orderId)
+}
@@ -0,0 +1,160 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRefunder records the refunds issued. Its during hook runs while a
+// refund is in flight, as a concurrent caller would.
+type fakeRefunder struct {
+	err    error
+	during func()
+	keys   []string
+}
+
+func (r *fakeRefunder) Refund(ctx context.Context, o Order, amount float64, idempotencyKey string) error {
+	if r.during != nil {
+		r.during()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	r.keys = append(r.keys, idempotencyKey)
+	return nil
+}
+
+func TestRefundReturn(t *testing.T) {
+	errRefund := errors.New("refund failed")
+
+	tests := []struct {
+		name   string
+		status ReturnStatus
+		// since is how long ago the return was last updated.
+		since       time.Duration
+		refundErr   error
+		wantErr     error
+		wantStatus  ReturnStatus
+		wantRefunds int
+		wantOrder   Status
+	}{
+		{name: "received", status: ReturnReceived, wantStatus: ReturnRefunded, wantRefunds: 1, wantOrder: StatusRefunded},
+		{name: "failed refund", status: ReturnReceived, refundErr: errRefund, wantErr: errRefund, wantStatus: ReturnReceived, wantOrder: StatusDelivered},
+		{name: "already refunding", status: ReturnRefunding, wantErr: ErrInvalidReturnTransition, wantStatus: ReturnRefunding, wantOrder: StatusDelivered},
+		{name: "left refunding", status: ReturnRefunding, since: time.Hour, wantStatus: ReturnRefunded, wantRefunds: 1, wantOrder: StatusRefunded},
+		{name: "left refunding, failed refund", status: ReturnRefunding, since: time.Hour, refundErr: errRefund, wantErr: errRefund, wantStatus: ReturnReceived, wantOrder: StatusDelivered},
+		{name: "already refunded", status: ReturnRefunded, wantErr: ErrInvalidReturnTransition, wantStatus: ReturnRefunded, wantOrder: StatusDelivered},
+		{name: "not received", status: ReturnApproved, wantErr: ErrInvalidReturnTransition, wantStatus: ReturnApproved, wantOrder: StatusDelivered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newFakeRepository(Order{
+				ID:        "o1",
+				AccountId: "acc",
+				Status:    StatusDelivered,
+				Products:  []OrderedProduct{{ID: "p1", Quantity: 1, Price: 10}},
+			})
+			repository.returns["r1"] = Return{
+				ID:           "r1",
+				OrderID:      "o1",
+				Status:       tt.status,
+				Lines:        []ReturnLine{{ProductID: "p1", Quantity: 1, Amount: 10}},
+				RefundAmount: 10,
+				UpdatedAt:    time.Now().UTC().Add(-tt.since),
+			}
+			refunder := &fakeRefunder{err: tt.refundErr}
+			s := NewService(repository, WithRefunder(refunder))
+
+			_, err := s.RefundReturn(context.Background(), "r1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefundReturn() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := repository.returnStatus("r1"); got != tt.wantStatus {
+				t.Errorf("return is %s, want %s", got, tt.wantStatus)
+			}
+			if len(refunder.keys) != tt.wantRefunds {
+				t.Errorf("refunds issued = %d, want %d", len(refunder.keys), tt.wantRefunds)
+			}
+			for _, key := range refunder.keys {
+				if key != "r1" {
+					t.Errorf("refund keyed %q, want the return id", key)
+				}
+			}
+			if got := repository.status("o1"); got != tt.wantOrder {
+				t.Errorf("order is %s, want %s", got, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestRefundReturnConcurrentCall(t *testing.T) {
+	repository := newFakeRepository(Order{ID: "o1", AccountId: "acc", Status: StatusDelivered})
+	repository.returns["r1"] = Return{ID: "r1", OrderID: "o1", Status: ReturnReceived, RefundAmount: 10}
+	refunder := &fakeRefunder{}
+	s := NewService(repository, WithRefunder(refunder))
+
+	var concurrentErr error
+	refunder.during = func() {
+		refunder.during = nil
+		_, concurrentErr = s.RefundReturn(context.Background(), "r1")
+	}
+
+	if _, err := s.RefundReturn(context.Background(), "r1"); err != nil {
+		t.Fatalf("RefundReturn() error = %v", err)
+	}
+	if !errors.Is(concurrentErr, ErrInvalidReturnTransition) {
+		t.Errorf("concurrent RefundReturn() error = %v, want %v", concurrentErr, ErrInvalidReturnTransition)
+	}
+	if len(refunder.keys) != 1 {
+		t.Errorf("refunds issued = %d, want 1", len(refunder.keys))
+	}
+}
+
+func TestResumeRefunds(t *testing.T) {
+	now := time.Now().UTC()
+	repository := newFakeRepository(Order{ID: "o1", AccountId: "acc", Status: StatusDelivered})
+	for id, ret := range map[string]Return{
+		"stale":    {Status: ReturnRefunding, UpdatedAt: now.Add(-time.Hour)},
+		"recent":   {Status: ReturnRefunding, UpdatedAt: now},
+		"received": {Status: ReturnReceived, UpdatedAt: now.Add(-time.Hour)},
+	} {
+		ret.ID, ret.OrderID, ret.RefundAmount = id, "o1", 10
+		repository.returns[id] = ret
+	}
+	refunder := &fakeRefunder{}
+	s := NewService(repository, WithRefunder(refunder))
+
+	if err := s.ResumeRefunds(context.Background()); err != nil {
+		t.Fatalf("ResumeRefunds() error = %v", err)
+	}
+
+	if len(refunder.keys) != 1 || refunder.keys[0] != "stale" {
+		t.Errorf("refunds issued with keys %v, want the stale return's", refunder.keys)
+	}
+	want := map[string]ReturnStatus{"stale": ReturnRefunded, "recent": ReturnRefunding, "received": ReturnReceived}
+	for id, status := range want {
+		if got := repository.returnStatus(id); got != status {
+			t.Errorf("return %s is %s, want %s", id, got, status)
+		}
+	}
+}
+
+func TestRefundReturnClaimedOnce(t *testing.T) {
+	repository := newFakeRepository(Order{ID: "o1", AccountId: "acc", Status: StatusDelivered})
+	repository.returns["r1"] = Return{ID: "r1", OrderID: "o1", Status: ReturnRefunding, RefundAmount: 10, UpdatedAt: time.Now().UTC().Add(-time.Hour)}
+	s := NewService(repository, WithRefunder(&fakeRefunder{})).(*orderService)
+
+	// Two recoverers read the stale return before either claims it.
+	first, _ := repository.GetReturn(context.Background(), "r1")
+	second, _ := repository.GetReturn(context.Background(), "r1")
+
+	if err := s.claimRefund(context.Background(), first); err != nil {
+		t.Fatalf("first claimRefund() error = %v", err)
+	}
+	if err := s.claimRefund(context.Background(), second); !errors.Is(err, ErrInvalidReturnTransition) {
+		t.Errorf("second claimRefund() error = %v, want %v", err, ErrInvalidReturnTransition)
+	}
+}
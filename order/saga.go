@@ -0,0 +1,280 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+type SagaState string
+
+const (
+	SagaRunning      SagaState = "running"
+	SagaCompensating SagaState = "compensating"
+	SagaCompleted    SagaState = "completed"
+	SagaAborted      SagaState = "aborted"
+)
+
+const (
+	sagaExecute    = "execute"
+	sagaCompensate = "compensate"
+)
+
+// SagaStep is one local transaction of the checkout saga together with
+// the action that semantically undoes it.
+//
+// Both actions may run more than once, after a retry or a crash, so they
+// must be idempotent. Compensate may also be called for a step whose
+// Execute never took effect and has to tolerate that.
+type SagaStep interface {
+	Name() string
+	Execute(ctx context.Context, c *Checkout) error
+	Compensate(ctx context.Context, c *Checkout) error
+}
+
+// SagaRecord is the persisted state of a saga. Step is the index of the
+// step to execute next while running, or to compensate next while
+// compensating.
+type SagaRecord struct {
+	ID        string
+	State     SagaState
+	Step      int
+	Checkout  Checkout
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SagaLog persists saga records so that sagas interrupted by a crash can be
+// finished by the next process.
+type SagaLog interface {
+	CreateSaga(ctx context.Context, rec SagaRecord) error
+	SaveSaga(ctx context.Context, rec SagaRecord) error
+	LogSagaAttempt(ctx context.Context, sagaId, step, action string, attempt int, err error) error
+	// ListPendingSagas returns the running and compensating sagas last
+	// saved before updatedBefore.
+	ListPendingSagas(ctx context.Context, updatedBefore time.Time) ([]SagaRecord, error)
+	// ClaimSaga takes a pending saga over by moving its updated time from
+	// the one it was listed with to at. It fails with ErrSagaClaimed if the
+	// saga was saved in between.
+	ClaimSaga(ctx context.Context, id string, updatedAt, at time.Time) error
+}
+
+var ErrSagaClaimed = errors.New("saga claimed by another process")
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying a step cannot fix,
+// like an unknown account.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Backoff: 100 * time.Millisecond}
+
+// Saga runs its steps in order and, if one of them fails for good,
+// compensates the steps already run in reverse order.
+type Saga struct {
+	log   SagaLog
+	steps []SagaStep
+	retry RetryPolicy
+	// compensationTimeout bounds compensations, which run detached from
+	// the caller's context so that a client timeout does not stop them.
+	compensationTimeout time.Duration
+	// staleAfter is how long a pending saga goes unsaved before Recover
+	// takes it for abandoned. It outlasts a request and a compensation so
+	// that sagas still driven by a live replica are left alone.
+	staleAfter time.Duration
+}
+
+func NewSaga(log SagaLog, retry RetryPolicy, steps ...SagaStep) *Saga {
+	if retry.Attempts < 1 {
+		retry.Attempts = 1
+	}
+	return &Saga{
+		log:                 log,
+		steps:               steps,
+		retry:               retry,
+		compensationTimeout: 30 * time.Second,
+		staleAfter:          2 * time.Minute,
+	}
+}
+
+// Run starts a new saga for the checkout and drives it to the end. On
+// success c holds the outputs of every step. The returned error is the one
+// of the failed step, after the saga was compensated.
+func (s *Saga) Run(ctx context.Context, c *Checkout) error {
+	now := time.Now().UTC()
+	rec := &SagaRecord{
+		ID:        ksuid.New().String(),
+		State:     SagaRunning,
+		Checkout:  *c,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.log.CreateSaga(ctx, *rec); err != nil {
+		return err
+	}
+
+	err := s.resume(ctx, rec)
+	*c = rec.Checkout
+	return err
+}
+
+// Recover finishes the sagas abandoned by a crashed or restarted process,
+// that is the pending ones not saved for staleAfter. The callers of those
+// already got an error, so running sagas are rolled back rather than
+// completed. Each saga is claimed first, so that concurrent replicas do not
+// recover it twice.
+func (s *Saga) Recover(ctx context.Context) error {
+	pending, err := s.log.ListPendingSagas(ctx, time.Now().UTC().Add(-s.staleAfter))
+	if err != nil {
+		return err
+	}
+
+	for i := range pending {
+		rec := &pending[i]
+		now := time.Now().UTC()
+		err := s.log.ClaimSaga(ctx, rec.ID, rec.UpdatedAt, now)
+		if errors.Is(err, ErrSagaClaimed) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rec.UpdatedAt = now
+
+		if rec.State == SagaRunning {
+			if err := s.abort(ctx, rec, errors.New("interrupted by restart")); err != nil {
+				return err
+			}
+		}
+		if err := s.compensate(ctx, rec); err != nil {
+			slog.ErrorContext(ctx, "saga not recovered", "saga", rec.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// RecoverSagas runs s.Recover every so often until ctx is done, starting
+// right away.
+func RecoverSagas(ctx context.Context, s *Saga, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Recover(ctx); err != nil {
+			slog.ErrorContext(ctx, "checkout sagas not recovered", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Saga) resume(ctx context.Context, rec *SagaRecord) error {
+	for rec.State == SagaRunning && rec.Step < len(s.steps) {
+		step := s.steps[rec.Step]
+		if err := s.attempt(ctx, rec, step.Name(), sagaExecute, step.Execute); err != nil {
+			stepErr := fmt.Errorf("%s: %w", step.Name(), err)
+			if err := s.abort(ctx, rec, stepErr); err != nil {
+				return err
+			}
+			if err := s.compensate(ctx, rec); err != nil {
+				slog.ErrorContext(ctx, "saga not compensated", "saga", rec.ID, "error", err)
+			}
+			return stepErr
+		}
+
+		rec.Step++
+		if err := s.save(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	rec.State = SagaCompleted
+	return s.save(ctx, rec)
+}
+
+// abort switches the saga to compensation, starting with the current step
+// since it may have partly taken effect.
+func (s *Saga) abort(ctx context.Context, rec *SagaRecord, cause error) error {
+	rec.State = SagaCompensating
+	rec.LastError = cause.Error()
+	if rec.Step >= len(s.steps) {
+		rec.Step = len(s.steps) - 1
+	}
+	return s.save(ctx, rec)
+}
+
+// compensate undoes the steps of a compensating saga. A compensation that
+// keeps failing leaves the saga compensating, for Recover to pick up.
+func (s *Saga) compensate(ctx context.Context, rec *SagaRecord) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.compensationTimeout)
+	defer cancel()
+
+	for rec.Step >= 0 {
+		step := s.steps[rec.Step]
+		if err := s.attempt(ctx, rec, step.Name(), sagaCompensate, step.Compensate); err != nil {
+			return fmt.Errorf("%s: %w", step.Name(), err)
+		}
+
+		rec.Step--
+		if err := s.save(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	rec.Step = 0
+	rec.State = SagaAborted
+	return s.save(ctx, rec)
+}
+
+func (s *Saga) attempt(ctx context.Context, rec *SagaRecord, name, action string, fn func(context.Context, *Checkout) error) error {
+	backoff := s.retry.Backoff
+	var err error
+	for attempt := 1; attempt <= s.retry.Attempts; attempt++ {
+		err = fn(ctx, &rec.Checkout)
+		if logErr := s.log.LogSagaAttempt(ctx, rec.ID, name, action, attempt, err); logErr != nil {
+			slog.WarnContext(ctx, "saga attempt not logged", "saga", rec.ID, "step", name, "error", logErr)
+		}
+		if err == nil || isPermanent(err) || attempt == s.retry.Attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+func (s *Saga) save(ctx context.Context, rec *SagaRecord) error {
+	rec.UpdatedAt = time.Now().UTC()
+	return s.log.SaveSaga(context.WithoutCancel(ctx), *rec)
+}
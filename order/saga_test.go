@@ -0,0 +1,371 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errInjected = errors.New("injected failure")
+
+// memorySagaLog is an in-memory SagaLog.
+type memorySagaLog struct {
+	mu      sync.Mutex
+	records map[string]SagaRecord
+}
+
+func newMemorySagaLog() *memorySagaLog {
+	return &memorySagaLog{records: map[string]SagaRecord{}}
+}
+
+func (l *memorySagaLog) CreateSaga(ctx context.Context, rec SagaRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.records[rec.ID]; ok {
+		return fmt.Errorf("saga %s already exists", rec.ID)
+	}
+	l.records[rec.ID] = rec
+	return nil
+}
+
+func (l *memorySagaLog) SaveSaga(ctx context.Context, rec SagaRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[rec.ID] = rec
+	return nil
+}
+
+func (l *memorySagaLog) LogSagaAttempt(ctx context.Context, sagaId, step, action string, attempt int, err error) error {
+	return nil
+}
+
+func (l *memorySagaLog) ListPendingSagas(ctx context.Context, updatedBefore time.Time) ([]SagaRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := []SagaRecord{}
+	for _, rec := range l.records {
+		if (rec.State == SagaRunning || rec.State == SagaCompensating) && rec.UpdatedAt.Before(updatedBefore) {
+			pending = append(pending, rec)
+		}
+	}
+	return pending, nil
+}
+
+func (l *memorySagaLog) ClaimSaga(ctx context.Context, id string, updatedAt, at time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.records[id]
+	if !ok || !rec.UpdatedAt.Equal(updatedAt) {
+		return ErrSagaClaimed
+	}
+	rec.UpdatedAt = at
+	l.records[id] = rec
+	return nil
+}
+
+// age makes every saga look last saved d earlier.
+func (l *memorySagaLog) age(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, rec := range l.records {
+		rec.UpdatedAt = rec.UpdatedAt.Add(-d)
+		l.records[id] = rec
+	}
+}
+
+func (l *memorySagaLog) states() []SagaState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	states := []SagaState{}
+	for _, rec := range l.records {
+		states = append(states, rec.State)
+	}
+	return states
+}
+
+// journal records the actions run by faulty steps, as "execute:name" and
+// "compensate:name".
+type journal struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (j *journal) add(call string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.calls = append(j.calls, call)
+}
+
+func (j *journal) get() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Clone(j.calls)
+}
+
+// faultyStep is a step that records its actions and fails them on demand.
+type faultyStep struct {
+	name    string
+	journal *journal
+	// executeFailures and compensateFailures are the number of calls that
+	// fail before the action succeeds, -1 to fail every call.
+	executeFailures    int
+	compensateFailures int
+	// permanent makes the failures of Execute permanent.
+	permanent bool
+}
+
+func (s *faultyStep) Name() string { return s.name }
+
+func (s *faultyStep) Execute(ctx context.Context, c *Checkout) error {
+	s.journal.add("execute:" + s.name)
+	if s.executeFailures != 0 {
+		s.executeFailures--
+		if s.permanent {
+			return Permanent(errInjected)
+		}
+		return errInjected
+	}
+	return nil
+}
+
+func (s *faultyStep) Compensate(ctx context.Context, c *Checkout) error {
+	s.journal.add("compensate:" + s.name)
+	if s.compensateFailures != 0 {
+		s.compensateFailures--
+		return errInjected
+	}
+	return nil
+}
+
+// checkoutStepNames is the shape of the checkout saga with payment.
+var checkoutStepNames = []string{"verify_account", "price_products", "place_order", "authorize_payment", "capture_payment"}
+
+var testRetryPolicy = RetryPolicy{Attempts: 3}
+
+// newTestSaga returns a saga of faulty steps named after the checkout
+// steps, along with the steps to inject failures into.
+func newTestSaga() (*Saga, *memorySagaLog, *journal, map[string]*faultyStep) {
+	j := &journal{}
+	byName := map[string]*faultyStep{}
+	steps := make([]SagaStep, 0, len(checkoutStepNames))
+	for _, name := range checkoutStepNames {
+		s := &faultyStep{name: name, journal: j}
+		byName[name] = s
+		steps = append(steps, s)
+	}
+	sagaLog := newMemorySagaLog()
+	return NewSaga(sagaLog, testRetryPolicy, steps...), sagaLog, j, byName
+}
+
+func calls(action string, names ...string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		out = append(out, action+":"+name)
+	}
+	return out
+}
+
+func checkSaga(t *testing.T, j *journal, sagaLog *memorySagaLog, want []string, state SagaState) {
+	t.Helper()
+	if got := j.get(); !slices.Equal(got, want) {
+		t.Errorf("ran %v\nwant %v", got, want)
+	}
+	checkState(t, sagaLog, state)
+}
+
+func checkState(t *testing.T, sagaLog *memorySagaLog, state SagaState) {
+	t.Helper()
+	for _, got := range sagaLog.states() {
+		if got != state {
+			t.Errorf("saga is %s, want %s", got, state)
+		}
+	}
+}
+
+func TestSagaCompensatesFailedStep(t *testing.T) {
+	tests := []struct {
+		failing     string
+		executed    []string
+		compensated []string
+	}{
+		{
+			failing:     "verify_account",
+			compensated: []string{"verify_account"},
+		},
+		{
+			failing:     "price_products",
+			executed:    []string{"verify_account"},
+			compensated: []string{"price_products", "verify_account"},
+		},
+		{
+			failing:     "place_order",
+			executed:    []string{"verify_account", "price_products"},
+			compensated: []string{"place_order", "price_products", "verify_account"},
+		},
+		{
+			failing:     "authorize_payment",
+			executed:    []string{"verify_account", "price_products", "place_order"},
+			compensated: []string{"authorize_payment", "place_order", "price_products", "verify_account"},
+		},
+		{
+			failing:     "capture_payment",
+			executed:    []string{"verify_account", "price_products", "place_order", "authorize_payment"},
+			compensated: []string{"capture_payment", "authorize_payment", "place_order", "price_products", "verify_account"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.failing, func(t *testing.T) {
+			saga, sagaLog, j, steps := newTestSaga()
+			steps[tt.failing].executeFailures = -1
+
+			err := saga.Run(context.Background(), &Checkout{})
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("Run() error = %v, want %v", err, errInjected)
+			}
+
+			want := calls("execute", tt.executed...)
+			for range testRetryPolicy.Attempts {
+				want = append(want, "execute:"+tt.failing)
+			}
+			want = append(want, calls("compensate", tt.compensated...)...)
+			checkSaga(t, j, sagaLog, want, SagaAborted)
+		})
+	}
+}
+
+func TestSagaDoesNotRetryPermanentFailure(t *testing.T) {
+	saga, sagaLog, j, steps := newTestSaga()
+	steps["verify_account"].executeFailures = -1
+	steps["verify_account"].permanent = true
+
+	if err := saga.Run(context.Background(), &Checkout{}); !errors.Is(err, errInjected) {
+		t.Fatalf("Run() error = %v, want %v", err, errInjected)
+	}
+	checkSaga(t, j, sagaLog, []string{"execute:verify_account", "compensate:verify_account"}, SagaAborted)
+}
+
+func TestSagaRetriesTransientFailures(t *testing.T) {
+	saga, sagaLog, j, steps := newTestSaga()
+	want := []string{}
+	for _, name := range checkoutStepNames {
+		steps[name].executeFailures = 1
+		want = append(want, "execute:"+name, "execute:"+name)
+	}
+
+	if err := saga.Run(context.Background(), &Checkout{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	checkSaga(t, j, sagaLog, want, SagaCompleted)
+}
+
+func TestSagaRecoverCompensatesInterruptedSaga(t *testing.T) {
+	// The process dies after each step in turn: the saga log is left as it
+	// was then, and recovery must undo every step that may have run.
+	for crashed, name := range checkoutStepNames {
+		t.Run("after "+name, func(t *testing.T) {
+			saga, sagaLog, j, _ := newTestSaga()
+			updated := time.Now().UTC().Add(-saga.staleAfter)
+			err := sagaLog.CreateSaga(context.Background(), SagaRecord{ID: "crashed", State: SagaRunning, Step: crashed + 1, UpdatedAt: updated})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := saga.Recover(context.Background()); err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+
+			want := []string{}
+			for i := min(crashed+1, len(checkoutStepNames)-1); i >= 0; i-- {
+				want = append(want, "compensate:"+checkoutStepNames[i])
+			}
+			checkSaga(t, j, sagaLog, want, SagaAborted)
+		})
+	}
+}
+
+func TestSagaFailedCompensationIsRecovered(t *testing.T) {
+	last := checkoutStepNames[len(checkoutStepNames)-1]
+
+	for _, name := range checkoutStepNames {
+		t.Run(name, func(t *testing.T) {
+			saga, sagaLog, j, steps := newTestSaga()
+			steps[last].executeFailures = -1
+			steps[last].permanent = true
+			steps[name].compensateFailures = testRetryPolicy.Attempts
+
+			if err := saga.Run(context.Background(), &Checkout{}); !errors.Is(err, errInjected) {
+				t.Fatalf("Run() error = %v, want %v", err, errInjected)
+			}
+			// Left compensating for recovery to finish.
+			checkState(t, sagaLog, SagaCompensating)
+			sagaLog.age(saga.staleAfter)
+
+			if err := saga.Recover(context.Background()); err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+			checkState(t, sagaLog, SagaAborted)
+			if got := j.get(); got[len(got)-1] != "compensate:"+checkoutStepNames[0] {
+				t.Errorf("recovery ended with %s, want the first step compensated", got[len(got)-1])
+			}
+		})
+	}
+}
+
+func TestSagaRecoverLeavesLiveSagas(t *testing.T) {
+	tests := []struct {
+		name string
+		// age is how long ago the saga was last saved.
+		age time.Duration
+		// claimed is set when another replica claims the saga first.
+		claimed bool
+	}{
+		{name: "recently saved", age: time.Second},
+		{name: "claimed by another replica", age: time.Hour, claimed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saga, sagaLog, j, _ := newTestSaga()
+			updated := time.Now().UTC().Add(-tt.age)
+			err := sagaLog.CreateSaga(context.Background(), SagaRecord{ID: "live", State: SagaRunning, Step: 1, UpdatedAt: updated})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.claimed {
+				saga.log = &claimingSagaLog{memorySagaLog: sagaLog}
+			}
+
+			if err := saga.Recover(context.Background()); err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+			if got := j.get(); len(got) != 0 {
+				t.Errorf("steps run = %v, want none", got)
+			}
+			checkState(t, sagaLog, SagaRunning)
+		})
+	}
+}
+
+// claimingSagaLog lets another replica claim every saga right after it is
+// listed.
+type claimingSagaLog struct {
+	*memorySagaLog
+}
+
+func (l *claimingSagaLog) ListPendingSagas(ctx context.Context, updatedBefore time.Time) ([]SagaRecord, error) {
+	pending, err := l.memorySagaLog.ListPendingSagas(ctx, updatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range pending {
+		if err := l.memorySagaLog.ClaimSaga(ctx, rec.ID, rec.UpdatedAt, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
@@ -5,127 +5,242 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"net"
+	"log/slog"
 
-	"github.com/lichb0rn/go-microservices/account"
 	"github.com/lichb0rn/go-microservices/catalog"
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/grpcserver"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
 	"github.com/lichb0rn/go-microservices/order/pb"
+	"github.com/lichb0rn/go-microservices/promotion"
+	"github.com/lichb0rn/go-microservices/shipping"
+	"github.com/segmentio/ksuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// errorCodes maps the order errors to the codes they are sent with.
+var errorCodes = grpcerr.Codes{
+	ErrNotFound:                      codes.NotFound,
+	ErrAccountNotFound:               codes.NotFound,
+	ErrShipmentNotFound:              codes.NotFound,
+	ErrReturnNotFound:                codes.NotFound,
+	promotion.ErrCouponNotFound:      codes.NotFound,
+	ErrProductsNotFound:              codes.InvalidArgument,
+	ErrIdempotencyMismatch:           codes.InvalidArgument,
+	ErrInvalidStatus:                 codes.InvalidArgument,
+	ErrInvalidPromotion:              codes.InvalidArgument,
+	ErrUnknownCarrier:                codes.InvalidArgument,
+	ErrInvalidShipment:               codes.InvalidArgument,
+	ErrInvalidReturn:                 codes.InvalidArgument,
+	ErrInvalidReport:                 codes.InvalidArgument,
+	shipping.ErrMethodNotFound:       codes.InvalidArgument,
+	shipping.ErrMethodUnavailable:    codes.InvalidArgument,
+	ErrNotOwner:                      codes.PermissionDenied,
+	ErrDuplicateCoupon:               codes.AlreadyExists,
+	ErrInvalidTransition:             codes.FailedPrecondition,
+	ErrNotCancellable:                codes.FailedPrecondition,
+	ErrPaymentDeclined:               codes.FailedPrecondition,
+	ErrCheckoutAborted:               codes.FailedPrecondition,
+	promotion.ErrCouponInactive:      codes.FailedPrecondition,
+	promotion.ErrCouponExhausted:     codes.FailedPrecondition,
+	promotion.ErrCouponNotApplicable: codes.FailedPrecondition,
+	ErrNotShippable:                  codes.FailedPrecondition,
+	ErrOverShipped:                   codes.FailedPrecondition,
+	ErrNotReturnable:                 codes.FailedPrecondition,
+	ErrOverReturned:                  codes.FailedPrecondition,
+	ErrInvalidReturnTransition:       codes.FailedPrecondition,
+	ErrRefundUnavailable:             codes.FailedPrecondition,
+	ErrRefundExceedsPayments:         codes.FailedPrecondition,
+}
+
 type grpcServer struct {
 	pb.UnimplementedOrderServiceServer
 	service       Service
-	accountClient *account.Client
+	checkout      *Saga
 	catalogClient *catalog.Client
 }
 
-func ListendGRPC(s Service, accountUrl, catalogUrl string, port int) error {
-	accountClient, err := account.NewClient(accountUrl)
-	if err != nil {
-		return err
-	}
-	catalogClient, err := catalog.NewClient(catalogUrl)
-	if err != nil {
-		accountClient.Close()
-		return err
-	}
-
-	lis, err := net.Listen("tcp", ":"+fmt.Sprint(port))
-	if err != nil {
-		accountClient.Close()
-		catalogClient.Close()
-		return err
-	}
-	server := grpc.NewServer()
+// ListendGRPC serves the order service, placing orders with the checkout
+// saga. The catalog client stays owned by the caller.
+func ListendGRPC(s Service, checkout *Saga, catalogClient *catalog.Client, checker *health.Checker, chain interceptor.Chain, port int) (*grpcserver.Server, error) {
+	server := grpc.NewServer(append(chain.ServerOptions(), grpcserver.KeepaliveEnforcement())...)
 	pb.RegisterOrderServiceServer(server, &grpcServer{
 		service:       s,
-		accountClient: accountClient,
+		checkout:      checkout,
 		catalogClient: catalogClient,
 	})
+	checker.Register(server)
 	reflection.Register(server)
-	return server.Serve(lis)
+	return grpcserver.New(server, checker, port)
 }
 
 func (s *grpcServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
-	_, err := s.accountClient.GetOne(ctx, r.AccountId)
-	if err != nil {
-		log.Panicln("Account not found: ", err)
-		return nil, errors.New("account not found")
+	requested := make([]OrderedProduct, 0, len(r.Products))
+	for _, rp := range r.Products {
+		requested = append(requested, OrderedProduct{ID: rp.ProductId, Quantity: int(rp.Quantity)})
 	}
 
-	productIds := make([]string, 0, len(r.Products))
-	orderedProducts, err := s.catalogClient.GetProducts(ctx, 0, 0, productIds, "")
-	if err != nil {
-		log.Panicln("Products not found: ", err)
-		return nil, errors.New("products not found")
+	c := &Checkout{
+		OrderID:         ksuid.New().String(),
+		AccountID:       r.AccountId,
+		Requested:       requested,
+		Coupon:          r.Coupon,
+		ShippingAddress: addressFromProto(r.ShippingAddress),
+		ShippingMethod:  r.ShippingMethod,
 	}
-
-	products := make([]OrderedProduct, 0, len(orderedProducts))
-	for _, p := range orderedProducts {
-		product := OrderedProduct{
-			ID:          p.ID,
-			Price:       p.Price,
-			Name:        p.Name,
-			Description: p.Description,
-			Quantity:    0,
+	if r.IdempotencyKey != "" {
+		c.IdempotencyKey = IdempotencyKey{
+			Key:         r.IdempotencyKey,
+			Fingerprint: Fingerprint(*c),
 		}
 
-		for _, rp := range r.Products {
-			if rp.ProductId == p.ID {
-				product.Quantity = int(rp.Quantity)
-				break
+		o, err := s.service.GetByIdempotencyKey(ctx, r.AccountId, c.IdempotencyKey)
+		if err == nil {
+			o, err = replayed(o)
+		}
+		switch {
+		case err == nil:
+			orders := []Order{*o}
+			if err := s.fillProducts(ctx, orders); err != nil {
+				return nil, errorCodes.Status(ctx, err, "products not found")
 			}
+			return &pb.PostOrderResponse{Order: orderToProto(orders[0])}, nil
+		case errors.Is(err, ErrIdempotencyMismatch):
+			return nil, errorCodes.Status(ctx, grpcerr.Invalid(err, "idempotencyKey", "used for a different order"), "order not created")
+		case !errors.Is(err, ErrNotFound):
+			return nil, errorCodes.Status(ctx, err, "order not created")
 		}
+	}
+
+	err := s.checkout.Run(ctx, c)
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		err = grpcerr.Invalid(err, "accountId", "no such account")
+	case errors.Is(err, ErrProductsNotFound):
+		err = grpcerr.Invalid(err, "products", "none of the products were found")
+	case isCouponError(err):
+		err = grpcerr.Invalid(err, "coupon", err.Error())
+	case isShippingError(err):
+		err = grpcerr.Invalid(err, "shippingMethod", err.Error())
+	}
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "order not created")
+	}
 
-		if product.Quantity > 0 {
-			products = append(products, product)
+	o := c.Order
+	if c.Replayed {
+		// The order of a concurrent attempt, which may have failed or still
+		// be taking the payment: report where it stands now.
+		o, err = s.service.GetOne(ctx, c.OrderID)
+		if err == nil {
+			o, err = replayed(o)
+		}
+		if err != nil {
+			return nil, errorCodes.Status(ctx, err, "order not created")
 		}
 	}
+	return &pb.PostOrderResponse{Order: orderToProto(*o)}, nil
+}
 
-	order, err := s.service.Post(ctx, r.AccountId, products)
+func (s *grpcServer) GetOrder(ctx context.Context, r *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	o, err := s.service.GetOne(ctx, r.Id)
 	if err != nil {
-		log.Panicln("Order not created: ", err)
-		return nil, errors.New("order not created")
+		return nil, errorCodes.Status(ctx, err, "order not found")
 	}
 
-	orderProto := &pb.Order{
-		Id:         order.ID,
-		AccountId:  order.AccountId,
-		TotalPrice: order.TotalPrice,
-		Products:   []*pb.Order_OrderProduct{},
+	orders := []Order{*o}
+	if err := s.fillProducts(ctx, orders); err != nil {
+		return nil, errorCodes.Status(ctx, err, "products not found")
 	}
-	orderProto.CreatedAt, _ = order.CreatedAt.MarshalBinary()
 
-	for _, p := range order.Products {
-		orderProto.Products = append(orderProto.Products, &pb.Order_OrderProduct{
-			Id:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-			Quantity:    uint32(p.Quantity),
-		})
+	return &pb.GetOrderResponse{Order: orderToProto(orders[0])}, nil
+}
+
+func (s *grpcServer) GetByAccountId(ctx context.Context, r *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
+	filter := OrderFilter{
+		MinTotal:   r.MinTotal,
+		MaxTotal:   r.MaxTotal,
+		Descending: r.Descending,
+		Take:       r.Take,
+		After:      r.After,
+	}
+	filter.CreatedAfter.UnmarshalBinary(r.CreatedAfter)
+	filter.CreatedBefore.UnmarshalBinary(r.CreatedBefore)
+	for _, st := range r.Statuses {
+		next, err := ParseStatus(st)
+		if err != nil {
+			return nil, errorCodes.Status(ctx, grpcerr.Invalid(err, "statuses", fmt.Sprintf("unknown order status %q", st)), "orders not found")
+		}
+		filter.Statuses = append(filter.Statuses, next)
 	}
 
-	return &pb.PostOrderResponse{Order: orderProto}, nil
+	page, err := s.service.GetByAccountId(ctx, r.AccountId, filter)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "orders not found")
+	}
+	accountOrders := page.Orders
+
+	if err := s.fillProducts(ctx, accountOrders); err != nil {
+		return nil, errorCodes.Status(ctx, err, "products not found")
+	}
 
+	orders := make([]*pb.Order, 0, len(accountOrders))
+	for _, o := range accountOrders {
+		orders = append(orders, orderToProto(o))
+	}
+
+	return &pb.GetOrdersForAccountResponse{Orders: orders, NextCursor: page.NextCursor}, nil
 }
 
-func (s *grpcServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
-	accountOrders, err := s.service.GetByAccountId(ctx, r.AccountId)
+func (s *grpcServer) UpdateOrderStatus(ctx context.Context, r *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
+	next, err := ParseStatus(r.Status)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, grpcerr.Invalid(err, "status", fmt.Sprintf("unknown order status %q", r.Status)), "order status not updated")
+	}
+
+	o, err := s.service.UpdateStatus(ctx, r.Id, next, r.Actor, r.Reason)
 	if err != nil {
-		log.Println("Orders not found: ", err)
-		return nil, errors.New("orders not found")
+		return nil, errorCodes.Status(ctx, err, "order status not updated")
 	}
 
+	orders := []Order{*o}
+	if err := s.fillProducts(ctx, orders); err != nil {
+		return nil, errorCodes.Status(ctx, err, "products not found")
+	}
+
+	return &pb.UpdateOrderStatusResponse{Order: orderToProto(orders[0])}, nil
+}
+
+func (s *grpcServer) CancelOrder(ctx context.Context, r *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	o, err := s.service.Cancel(ctx, r.Id, r.AccountId, r.Reason)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "order not cancelled")
+	}
+
+	orders := []Order{*o}
+	if err := s.fillProducts(ctx, orders); err != nil {
+		return nil, errorCodes.Status(ctx, err, "products not found")
+	}
+
+	return &pb.CancelOrderResponse{Order: orderToProto(orders[0])}, nil
+}
+
+// fillProducts completes the ordered products with the name, description
+// and price from the catalog, since the order database only keeps ids.
+func (s *grpcServer) fillProducts(ctx context.Context, orders []Order) error {
 	productIdMap := map[string]bool{}
-	for _, o := range accountOrders {
+	for _, o := range orders {
 		for _, p := range o.Products {
 			productIdMap[p.ID] = true
 		}
 	}
+	if len(productIdMap) == 0 {
+		return nil
+	}
 
 	productIds := make([]string, 0, len(productIdMap))
 	for id := range productIdMap {
@@ -134,40 +249,394 @@ func (s *grpcServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersFor
 
 	products, err := s.catalogClient.GetProducts(ctx, 0, 0, productIds, "")
 	if err != nil {
-		log.Println("Products not found: ", err)
-		return nil, errors.New("products not found")
+		return err
 	}
 
-	orders := make([]*pb.Order, 0, len(accountOrders))
-	for _, o := range accountOrders {
-		op := &pb.Order{
-			Id:         o.ID,
-			AccountId:  o.AccountId,
-			TotalPrice: o.TotalPrice,
-			Products:   []*pb.Order_OrderProduct{},
-		}
-		op.CreatedAt, _ = o.CreatedAt.MarshalBinary()
-
-		for _, product := range o.Products {
+	for _, o := range orders {
+		for i := range o.Products {
+			product := &o.Products[i]
 			for _, p := range products {
 				if product.ID == p.ID {
 					product.Name = p.Name
 					product.Description = p.Description
-					product.Price = p.Price
+					if product.Price == 0 {
+						// Orders placed before prices were stored.
+						product.Price = p.Price
+					}
 					break
 				}
 			}
-			op.Products = append(op.Products, &pb.Order_OrderProduct{
-				Id:          product.ID,
-				Name:        product.Name,
-				Description: product.Description,
-				Price:       product.Price,
-				Quantity:    uint32(product.Quantity),
-			})
 		}
+	}
+	return nil
+}
+
+func orderToProto(o Order) *pb.Order {
+	op := &pb.Order{
+		Id:              o.ID,
+		AccountId:       o.AccountId,
+		Subtotal:        o.Subtotal,
+		DiscountTotal:   o.DiscountTotal,
+		TaxTotal:        o.TaxTotal,
+		TotalPrice:      o.TotalPrice,
+		Status:          string(o.Status),
+		Products:        []*pb.Order_OrderProduct{},
+		Coupon:          o.Coupon,
+		FreeShipping:    o.FreeShipping,
+		ShippingAddress: addressToProto(o.ShippingAddress),
+		ShippingMethod:  o.ShippingMethod,
+		ShippingCost:    o.ShippingCost,
+	}
+	op.CreatedAt, _ = o.CreatedAt.MarshalBinary()
+
+	for _, d := range o.Discounts {
+		op.Discounts = append(op.Discounts, &pb.Discount{
+			PromotionId: d.PromotionID,
+			Code:        d.Code,
+			Kind:        string(d.Kind),
+			ProductId:   d.ProductID,
+			Amount:      d.Amount,
+		})
+	}
+
+	for _, c := range o.History {
+		change := &pb.StatusChange{
+			From:   string(c.From),
+			To:     string(c.To),
+			Actor:  c.Actor,
+			Reason: c.Reason,
+		}
+		change.CreatedAt, _ = c.CreatedAt.MarshalBinary()
+		op.History = append(op.History, change)
+	}
 
-		orders = append(orders, op)
+	for _, p := range o.Products {
+		op.Products = append(op.Products, &pb.Order_OrderProduct{
+			Id:          p.ID,
+			Name:        p.Name,
+			Description: p.Description,
+			Price:       p.Price,
+			Quantity:    uint32(p.Quantity),
+			Discount:    p.Discount,
+			TaxClass:    p.TaxClass,
+			TaxRate:     p.TaxRate,
+			Tax:         p.Tax,
+		})
 	}
+	return op
+}
 
-	return &pb.GetOrdersForAccountResponse{Orders: orders}, nil
+func addressToProto(a Address) *pb.Address {
+	return &pb.Address{
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+func addressFromProto(a *pb.Address) Address {
+	if a == nil {
+		return Address{}
+	}
+	return Address{
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+func (s *grpcServer) CreatePromotion(ctx context.Context, r *pb.CreatePromotionRequest) (*pb.CreatePromotionResponse, error) {
+	if r.Promotion == nil {
+		return nil, errorCodes.Status(ctx, grpcerr.Invalid(ErrInvalidPromotion, "promotion", "is required"), "promotion not created")
+	}
+
+	p, err := s.service.CreatePromotion(ctx, promotionFromProto(r.Promotion))
+	if errors.Is(err, ErrDuplicateCoupon) {
+		err = grpcerr.Invalid(err, "promotion.code", "already in use")
+	}
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "promotion not created")
+	}
+	return &pb.CreatePromotionResponse{Promotion: promotionToProto(*p)}, nil
+}
+
+func promotionToProto(p promotion.Promotion) *pb.Promotion {
+	pp := &pb.Promotion{
+		Id:          p.ID,
+		Name:        p.Name,
+		Kind:        string(p.Kind),
+		Code:        p.Code,
+		Percent:     p.Percent,
+		Amount:      p.Amount,
+		BuyQuantity: uint32(p.BuyQuantity),
+		GetQuantity: uint32(p.GetQuantity),
+		ProductIds:  p.ProductIDs,
+		MinSubtotal: p.MinSubtotal,
+		UsageLimit:  uint32(p.UsageLimit),
+		UsageCount:  uint32(p.UsageCount),
+	}
+	if !p.StartsAt.IsZero() {
+		pp.StartsAt, _ = p.StartsAt.MarshalBinary()
+	}
+	if !p.EndsAt.IsZero() {
+		pp.EndsAt, _ = p.EndsAt.MarshalBinary()
+	}
+	return pp
+}
+
+func promotionFromProto(pp *pb.Promotion) promotion.Promotion {
+	p := promotion.Promotion{
+		ID:          pp.Id,
+		Name:        pp.Name,
+		Kind:        promotion.Kind(pp.Kind),
+		Code:        pp.Code,
+		Percent:     pp.Percent,
+		Amount:      pp.Amount,
+		BuyQuantity: int(pp.BuyQuantity),
+		GetQuantity: int(pp.GetQuantity),
+		ProductIDs:  pp.ProductIds,
+		MinSubtotal: pp.MinSubtotal,
+		UsageLimit:  int(pp.UsageLimit),
+		UsageCount:  int(pp.UsageCount),
+	}
+	if len(pp.StartsAt) > 0 {
+		p.StartsAt.UnmarshalBinary(pp.StartsAt)
+	}
+	if len(pp.EndsAt) > 0 {
+		p.EndsAt.UnmarshalBinary(pp.EndsAt)
+	}
+	return p
+}
+
+func (s *grpcServer) GetShippingQuotes(ctx context.Context, r *pb.GetShippingQuotesRequest) (*pb.GetShippingQuotesResponse, error) {
+	requested := make([]OrderedProduct, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		requested = append(requested, OrderedProduct{ID: l.ProductId, Quantity: int(l.Quantity)})
+	}
+
+	products, err := priceProducts(ctx, s.catalogClient, requested)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "products not found")
+	}
+
+	quotes, err := s.service.QuoteShipping(ctx, addressFromProto(r.Address), products)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "shipping not quoted")
+	}
+
+	res := &pb.GetShippingQuotesResponse{Quotes: []*pb.ShippingQuote{}}
+	for _, q := range quotes {
+		res.Quotes = append(res.Quotes, &pb.ShippingQuote{
+			MethodId: q.MethodID,
+			Name:     q.Name,
+			Cost:     q.Cost,
+		})
+	}
+	return res, nil
+}
+
+func (s *grpcServer) CreateShipment(ctx context.Context, r *pb.CreateShipmentRequest) (*pb.CreateShipmentResponse, error) {
+	lines := make([]ShipmentLine, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		lines = append(lines, ShipmentLine{ProductID: l.ProductId, Quantity: int(l.Quantity)})
+	}
+
+	sh, err := s.service.CreateShipment(ctx, r.OrderId, r.Carrier, lines)
+	if errors.Is(err, ErrUnknownCarrier) {
+		err = grpcerr.Invalid(err, "carrier", fmt.Sprintf("unknown carrier %q", r.Carrier))
+	}
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "shipment not created")
+	}
+	return &pb.CreateShipmentResponse{Shipment: shipmentToProto(*sh)}, nil
+}
+
+func (s *grpcServer) GetShipments(ctx context.Context, r *pb.GetShipmentsRequest) (*pb.GetShipmentsResponse, error) {
+	shipments, err := s.service.GetShipments(ctx, r.OrderId)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "shipments not found")
+	}
+
+	res := &pb.GetShipmentsResponse{Shipments: []*pb.Shipment{}}
+	for _, sh := range shipments {
+		res.Shipments = append(res.Shipments, shipmentToProto(sh))
+	}
+	return res, nil
+}
+
+func shipmentToProto(s Shipment) *pb.Shipment {
+	ps := &pb.Shipment{
+		Id:             s.ID,
+		OrderId:        s.OrderID,
+		Carrier:        s.Carrier,
+		TrackingNumber: s.TrackingNumber,
+		Status:         string(s.Status),
+	}
+	ps.CreatedAt, _ = s.CreatedAt.MarshalBinary()
+	ps.UpdatedAt, _ = s.UpdatedAt.MarshalBinary()
+	for _, l := range s.Lines {
+		ps.Lines = append(ps.Lines, &pb.Shipment_Line{ProductId: l.ProductID, Quantity: uint32(l.Quantity)})
+	}
+	for _, e := range s.Events {
+		pe := &pb.Shipment_Event{
+			Status:      string(e.Status),
+			Description: e.Description,
+			Location:    e.Location,
+		}
+		pe.OccurredAt, _ = e.OccurredAt.MarshalBinary()
+		ps.Events = append(ps.Events, pe)
+	}
+	return ps
+}
+
+func (s *grpcServer) RequestReturn(ctx context.Context, r *pb.RequestReturnRequest) (*pb.ReturnResponse, error) {
+	lines := make([]ReturnLine, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		lines = append(lines, ReturnLine{ProductID: l.ProductId, Quantity: int(l.Quantity)})
+	}
+
+	ret, err := s.service.RequestReturn(ctx, r.OrderId, r.AccountId, r.Reason, lines)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "return not requested")
+	}
+	return &pb.ReturnResponse{Return: returnToProto(*ret)}, nil
+}
+
+func (s *grpcServer) ApproveReturn(ctx context.Context, r *pb.ReviewReturnRequest) (*pb.ReturnResponse, error) {
+	ret, err := s.service.ApproveReturn(ctx, r.Id, r.Note)
+	return returnResponse(ctx, ret, err)
+}
+
+func (s *grpcServer) RejectReturn(ctx context.Context, r *pb.ReviewReturnRequest) (*pb.ReturnResponse, error) {
+	ret, err := s.service.RejectReturn(ctx, r.Id, r.Note)
+	return returnResponse(ctx, ret, err)
+}
+
+func (s *grpcServer) ReceiveReturn(ctx context.Context, r *pb.ReturnIdRequest) (*pb.ReturnResponse, error) {
+	ret, err := s.service.ReceiveReturn(ctx, r.Id)
+	return returnResponse(ctx, ret, err)
+}
+
+func (s *grpcServer) RefundReturn(ctx context.Context, r *pb.ReturnIdRequest) (*pb.ReturnResponse, error) {
+	ret, err := s.service.RefundReturn(ctx, r.Id)
+	return returnResponse(ctx, ret, err)
+}
+
+// returnResponse maps the outcome of a step of a return.
+func returnResponse(ctx context.Context, ret *Return, err error) (*pb.ReturnResponse, error) {
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "return not updated")
+	}
+	return &pb.ReturnResponse{Return: returnToProto(*ret)}, nil
+}
+
+func (s *grpcServer) GetReturns(ctx context.Context, r *pb.GetReturnsRequest) (*pb.GetReturnsResponse, error) {
+	returns, err := s.service.GetReturns(ctx, r.OrderId)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "returns not found")
+	}
+
+	res := &pb.GetReturnsResponse{Returns: []*pb.Return{}}
+	for _, ret := range returns {
+		res.Returns = append(res.Returns, returnToProto(ret))
+	}
+	return res, nil
+}
+
+func returnToProto(r Return) *pb.Return {
+	pr := &pb.Return{
+		Id:           r.ID,
+		OrderId:      r.OrderID,
+		AccountId:    r.AccountID,
+		Status:       string(r.Status),
+		Reason:       r.Reason,
+		Note:         r.Note,
+		RefundAmount: r.RefundAmount,
+	}
+	pr.CreatedAt, _ = r.CreatedAt.MarshalBinary()
+	pr.UpdatedAt, _ = r.UpdatedAt.MarshalBinary()
+	for _, l := range r.Lines {
+		pr.Lines = append(pr.Lines, &pb.Return_Line{
+			ProductId: l.ProductID,
+			Quantity:  uint32(l.Quantity),
+			Amount:    l.Amount,
+		})
+	}
+	return pr
+}
+
+// WatchOrders streams order events until the client goes away. A client
+// falling behind gets Aborted and should reload the orders before
+// watching again.
+func (s *grpcServer) WatchOrders(r *pb.WatchOrdersRequest, stream pb.OrderService_WatchOrdersServer) error {
+	ctx := stream.Context()
+	for e := range s.service.Watch(ctx, r.AccountId) {
+		orders := []Order{e.Order}
+		if err := s.fillProducts(ctx, orders); err != nil {
+			slog.WarnContext(ctx, "products not found", "error", err)
+		}
+
+		pe := &pb.OrderEvent{
+			Type:  string(e.Type),
+			Order: orderToProto(orders[0]),
+			From:  string(e.From),
+		}
+		pe.OccurredAt, _ = e.OccurredAt.MarshalBinary()
+		if err := stream.Send(pe); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return status.Error(codes.Aborted, "order events dropped, watch again")
+}
+
+func (s *grpcServer) GetSalesReport(ctx context.Context, r *pb.GetSalesReportRequest) (*pb.GetSalesReportResponse, error) {
+	f := ReportFilter{Period: ReportPeriod(r.Period), Top: int(r.Top)}
+	f.From.UnmarshalBinary(r.From)
+	f.To.UnmarshalBinary(r.To)
+
+	report, err := s.service.SalesReport(ctx, f)
+	if err != nil {
+		return nil, errorCodes.Status(ctx, err, "sales report not built")
+	}
+
+	pr := &pb.SalesReport{
+		Period:             string(report.Period),
+		TotalRevenue:       report.TotalRevenue,
+		Orders:             uint32(report.Orders),
+		AverageOrderValue:  report.AverageOrderValue,
+		TopByUnits:         productSalesToProto(report.TopByUnits),
+		TopByRevenue:       productSalesToProto(report.TopByRevenue),
+		NewCustomers:       uint32(report.Customers.New),
+		ReturningCustomers: uint32(report.Customers.Returning),
+	}
+	pr.From, _ = report.From.MarshalBinary()
+	pr.To, _ = report.To.MarshalBinary()
+	for _, p := range report.Revenue {
+		point := &pb.SalesReport_RevenuePoint{Orders: uint32(p.Orders), Revenue: p.Revenue}
+		point.Start, _ = p.Start.MarshalBinary()
+		pr.Revenue = append(pr.Revenue, point)
+	}
+	return &pb.GetSalesReportResponse{Report: pr}, nil
+}
+
+func productSalesToProto(products []ProductSales) []*pb.SalesReport_ProductSales {
+	result := make([]*pb.SalesReport_ProductSales, 0, len(products))
+	for _, p := range products {
+		result = append(result, &pb.SalesReport_ProductSales{
+			ProductId: p.ProductID,
+			Units:     uint32(p.Units),
+			Revenue:   p.Revenue,
+		})
+	}
+	return result
 }
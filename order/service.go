@@ -2,22 +2,82 @@ package order
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"time"
 
+	"github.com/lichb0rn/go-microservices/grpcerr"
+	"github.com/lichb0rn/go-microservices/promotion"
+	"github.com/lichb0rn/go-microservices/shipping"
 	"github.com/segmentio/ksuid"
 )
 
 type Service interface {
-	Post(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error)
-	GetByAccountId(ctx context.Context, accountId string) ([]Order, error)
+	Post(ctx context.Context, c Checkout) (*Order, error)
+	GetOne(ctx context.Context, id string) (*Order, error)
+	GetByIdempotencyKey(ctx context.Context, accountId string, key IdempotencyKey) (*Order, error)
+	UpdateStatus(ctx context.Context, id string, status Status, actor, reason string) (*Order, error)
+	// Cancel moves the order to cancelling, runs the cancellation hooks
+	// and cancels it. Cancelling an order left cancelling by a failed hook
+	// runs the hooks again.
+	Cancel(ctx context.Context, id, accountId, reason string) (*Order, error)
+	// ResumeCancellations completes the orders left cancelling for a while
+	// by a failed hook or a Cancel that was interrupted.
+	ResumeCancellations(ctx context.Context) error
+	GetByAccountId(ctx context.Context, accountId string, filter OrderFilter) (*OrderPage, error)
+	CreatePromotion(ctx context.Context, p promotion.Promotion) (*promotion.Promotion, error)
+	// QuoteShipping prices every shipping method available for the priced
+	// products and the address.
+	QuoteShipping(ctx context.Context, address Address, products []OrderedProduct) ([]shipping.Quote, error)
+	// CreateShipment ships some of the lines of a paid order with the carrier.
+	CreateShipment(ctx context.Context, orderId, carrier string, lines []ShipmentLine) (*Shipment, error)
+	GetShipments(ctx context.Context, orderId string) ([]Shipment, error)
+	// TrackShipments fetches new tracking events for the shipments in
+	// flight and advances the orders whose shipments were all delivered.
+	TrackShipments(ctx context.Context) error
+	// RequestReturn opens a return of some lines of a shipped order on
+	// behalf of the account owning it.
+	RequestReturn(ctx context.Context, orderId, accountId, reason string, lines []ReturnLine) (*Return, error)
+	ApproveReturn(ctx context.Context, id, note string) (*Return, error)
+	RejectReturn(ctx context.Context, id, note string) (*Return, error)
+	ReceiveReturn(ctx context.Context, id string) (*Return, error)
+	RefundReturn(ctx context.Context, id string) (*Return, error)
+	// ResumeRefunds refunds again the returns left refunding for a while
+	// by a RefundReturn that died or could not store the outcome.
+	ResumeRefunds(ctx context.Context) error
+	GetReturn(ctx context.Context, id string) (*Return, error)
+	GetReturns(ctx context.Context, orderId string) ([]Return, error)
+	// Watch streams the creations and status changes of the account's
+	// orders, or of all orders when accountId is empty, until ctx is done.
+	// The channel is closed early if the receiver falls behind.
+	Watch(ctx context.Context, accountId string) <-chan OrderEvent
+	// SalesReport aggregates the paid orders placed in the filter's range.
+	SalesReport(ctx context.Context, f ReportFilter) (*SalesReport, error)
 }
 
 type Order struct {
-	ID         string
-	CreatedAt  time.Time
-	TotalPrice float64
-	AccountId  string
-	Products   []OrderedProduct
+	ID        string
+	CreatedAt time.Time
+	// Subtotal is the sum of the lines before discounts, TotalPrice the
+	// grand total: Subtotal - DiscountTotal + TaxTotal + ShippingCost.
+	Subtotal      float64
+	DiscountTotal float64
+	TaxTotal      float64
+	TotalPrice    float64
+	AccountId     string
+	Status        Status
+	History       []StatusChange
+	Products      []OrderedProduct
+	// Coupon is the code the customer applied, Discounts what the
+	// promotions took off, per line.
+	Coupon          string
+	Discounts       []promotion.Discount
+	FreeShipping    bool
+	ShippingAddress Address
+	ShippingMethod  string
+	ShippingCost    float64
 }
 
 type OrderedProduct struct {
@@ -26,35 +86,561 @@ type OrderedProduct struct {
 	Description string
 	Price       float64
 	Quantity    int
+	// Discount is the amount taken off the line by promotions, Tax the
+	// tax owed on what is left at TaxRate.
+	Discount float64
+	TaxClass string
+	TaxRate  float64
+	Tax      float64
+	// Weight is the shipping weight of one unit. It comes from the catalog
+	// and is not stored with the order.
+	Weight float64
+}
+
+// OrderFilter narrows down and pages the orders of an account.
+// Zero values leave the corresponding criterion out.
+type OrderFilter struct {
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Statuses      []Status
+	MinTotal      *float64
+	MaxTotal      *float64
+	Descending    bool
+	Take          uint64
+	// After is the id of the last order of the previous page.
+	After string
+}
+
+type OrderPage struct {
+	Orders []Order
+	// NextCursor is the value for OrderFilter.After to fetch the next page,
+	// empty on the last page.
+	NextCursor string
 }
 
 type orderService struct {
-	repository Repository
+	repository        Repository
+	cancellationHooks []CancellationHook
+	taxCalculator     TaxCalculator
+	shippingMethods   []shipping.Method
+	carriers          map[string]CarrierAdapter
+	refunder          Refunder
+	events            *broadcaster
 }
 
-func NewService(repository Repository) Service {
-	return &orderService{repository}
+type ServiceOption func(*orderService)
+
+// WithCancellationHooks registers compensating actions run on Cancel.
+func WithCancellationHooks(hooks ...CancellationHook) ServiceOption {
+	return func(s *orderService) {
+		s.cancellationHooks = append(s.cancellationHooks, hooks...)
+	}
 }
 
-func (s *orderService) Post(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error) {
+// WithTaxCalculator taxes the orders placed. Without it no tax is charged.
+func WithTaxCalculator(c TaxCalculator) ServiceOption {
+	return func(s *orderService) {
+		s.taxCalculator = c
+	}
+}
+
+// WithShippingMethods sets the shipping methods customers can choose from.
+func WithShippingMethods(methods ...shipping.Method) ServiceOption {
+	return func(s *orderService) {
+		s.shippingMethods = append(s.shippingMethods, methods...)
+	}
+}
+
+// WithCarriers registers the carriers shipments can be sent with, by name.
+func WithCarriers(carriers ...CarrierAdapter) ServiceOption {
+	return func(s *orderService) {
+		for _, c := range carriers {
+			s.carriers[c.Name()] = c
+		}
+	}
+}
+
+func NewService(repository Repository, opts ...ServiceOption) Service {
+	s := &orderService{
+		repository: repository,
+		carriers:   map[string]CarrierAdapter{},
+		events:     newBroadcaster(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Post stores the order for a priced checkout under c.OrderID,
+// or a new id when it is empty. Promotions are evaluated against the lines,
+// which are then taxed on what is left after the discounts. Shipping is
+// charged when a method is chosen, unless a promotion makes it free.
+func (s *orderService) Post(ctx context.Context, c Checkout) (*Order, error) {
+	id := c.OrderID
+	if id == "" {
+		id = ksuid.New().String()
+	} else if o, err := s.repository.GetById(ctx, id); err == nil {
+		// A retried saga step, the first attempt did store the order. This
+		// must be known before the promotions are evaluated, since the
+		// order may have used up its own coupon.
+		return o, nil
+	}
+
+	now := time.Now().UTC()
+	lines := make([]promotion.Line, 0, len(c.Products))
+	for _, p := range c.Products {
+		lines = append(lines, promotion.Line{ProductID: p.ID, Price: p.Price, Quantity: p.Quantity})
+	}
+	promotions, err := s.repository.GetPromotions(ctx, c.Coupon)
+	if err != nil {
+		return nil, err
+	}
+	result, err := promotion.Evaluate(promotions, lines, c.Coupon, now)
+	if err != nil {
+		return nil, err
+	}
+
 	o := Order{
-		ID:         ksuid.New().String(),
-		CreatedAt:  time.Now().UTC(),
-		TotalPrice: 0.0,
-		AccountId:  accountId,
-		Products:   products,
+		ID:        id,
+		CreatedAt: now,
+		AccountId: c.AccountID,
+		Status:    StatusPending,
+		History: []StatusChange{{
+			To:        StatusPending,
+			Actor:     c.AccountID,
+			Reason:    "order placed",
+			CreatedAt: now,
+		}},
+		Products:        make([]OrderedProduct, 0, len(c.Products)),
+		Coupon:          c.Coupon,
+		Discounts:       result.Discounts,
+		FreeShipping:    result.FreeShipping,
+		ShippingAddress: c.ShippingAddress,
+		ShippingMethod:  c.ShippingMethod,
 	}
-	for _, p := range products {
-		o.TotalPrice += p.Price * float64(p.Quantity)
+	for _, p := range c.Products {
+		p.Discount = result.LineTotal(p.ID)
+		o.Products = append(o.Products, p)
+		o.Subtotal += p.Price * float64(p.Quantity)
+	}
+	o.Subtotal = roundCents(o.Subtotal)
+	o.DiscountTotal = result.Total()
+
+	if s.taxCalculator != nil {
+		taxes, err := s.taxCalculator.Calculate(ctx, c.ShippingAddress, o.Products)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range taxes {
+			o.Products[i].TaxRate = t.Rate
+			o.Products[i].Tax = t.Amount
+			o.TaxTotal += t.Amount
+		}
+		o.TaxTotal = roundCents(o.TaxTotal)
+	}
+	if c.ShippingMethod != "" {
+		quote, err := shipping.Find(s.shippingMethods, c.ShippingMethod, c.ShippingAddress.Country, shippingLines(c.Products))
+		if err != nil {
+			return nil, err
+		}
+		if !o.FreeShipping {
+			o.ShippingCost = quote.Cost
+		}
 	}
+	o.TotalPrice = roundCents(o.Subtotal - o.DiscountTotal + o.TaxTotal + o.ShippingCost)
 
-	err := s.repository.Put(ctx, o)
+	err = s.repository.Put(ctx, o, c.IdempotencyKey)
+	if errors.Is(err, errDuplicateOrder) {
+		// A retried saga step, the first attempt did store the order.
+		return s.repository.GetById(ctx, id)
+	}
+	if errors.Is(err, errDuplicateKey) {
+		// Lost the race against a concurrent retry, which already placed
+		// the order under its own id. The caller tells by the id.
+		return s.GetByIdempotencyKey(ctx, c.AccountID, c.IdempotencyKey)
+	}
 	if err != nil {
 		return nil, err
 	}
+
+	s.events.publish(OrderEvent{Type: EventCreated, Order: o, OccurredAt: now})
+	ordersPlaced.Inc()
+	ordersPlacedAmount.Add(o.TotalPrice)
 	return &o, nil
 }
 
-func (s *orderService) GetByAccountId(ctx context.Context, accountId string) ([]Order, error) {
-	return s.repository.GetByAccountId(ctx, accountId)
+func (s *orderService) GetOne(ctx context.Context, id string) (*Order, error) {
+	return s.repository.GetById(ctx, id)
+}
+
+// GetByIdempotencyKey returns the order placed with the given key, ErrNotFound
+// if the key was never used, or ErrIdempotencyMismatch if it was used for a
+// different request.
+func (s *orderService) GetByIdempotencyKey(ctx context.Context, accountId string, key IdempotencyKey) (*Order, error) {
+	orderId, fingerprint, err := s.repository.GetIdempotencyKey(ctx, accountId, key.Key)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != key.Fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+	return s.repository.GetById(ctx, orderId)
+}
+
+func (s *orderService) UpdateStatus(ctx context.Context, id string, status Status, actor, reason string) (*Order, error) {
+	o, err := s.repository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.Status.CanTransitionTo(status) {
+		return nil, ErrInvalidTransition
+	}
+	if err := s.changeStatus(ctx, o, status, actor, reason); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// changeStatus moves the order to status, unless it changed in the
+// meantime, and publishes the change.
+func (s *orderService) changeStatus(ctx context.Context, o *Order, status Status, actor, reason string) error {
+	c := StatusChange{
+		From:      o.Status,
+		To:        status,
+		Actor:     actor,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repository.UpdateStatus(ctx, o.ID, c); err != nil {
+		return err
+	}
+
+	o.Status = status
+	o.History = append(o.History, c)
+	s.events.publish(OrderEvent{Type: EventStatusChanged, Order: *o, From: c.From, OccurredAt: c.CreatedAt})
+	orderStatusChanges.Inc(string(o.Status))
+	return nil
+}
+
+// Cancel takes the order out of payment and fulfilment before the hooks
+// give anything back, so that an order cannot be both refunded and shipped.
+func (s *orderService) Cancel(ctx context.Context, id, accountId, reason string) (*Order, error) {
+	o, err := s.repository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.AccountId != accountId {
+		return nil, ErrNotOwner
+	}
+	if o.Status != StatusCancelling {
+		if !o.Status.CanTransitionTo(StatusCancelling) {
+			return nil, ErrNotCancellable
+		}
+		if reason == "" {
+			reason = defaultCancelReason
+		}
+		err := s.changeStatus(ctx, o, StatusCancelling, accountId, reason)
+		if errors.Is(err, ErrInvalidTransition) {
+			// Paid for, shipped or cancelled in the meantime.
+			return nil, ErrNotCancellable
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.completeCancellation(ctx, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *orderService) ResumeCancellations(ctx context.Context) error {
+	ids, err := s.repository.ListCancelling(ctx, time.Now().UTC().Add(-cancellationStaleAfter))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		o, err := s.repository.GetById(ctx, id)
+		if err == nil {
+			err = s.completeCancellation(ctx, o)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "cancellation not resumed", "order", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// completeCancellation runs the hooks of a cancelling order and cancels
+// it, on behalf of whoever asked for the cancellation and for their reason.
+func (s *orderService) completeCancellation(ctx context.Context, o *Order) error {
+	var requested StatusChange
+	for _, c := range o.History {
+		if c.To == StatusCancelling {
+			requested = c
+		}
+	}
+
+	for _, hook := range s.cancellationHooks {
+		if err := hook.Compensate(ctx, *o, requested.Reason); err != nil {
+			return fmt.Errorf("cancellation hook %s: %w", hook.Name(), err)
+		}
+	}
+
+	err := s.changeStatus(ctx, o, StatusCancelled, requested.Actor, requested.Reason)
+	if errors.Is(err, ErrInvalidTransition) {
+		// Completed concurrently, by a retry or ResumeCancellations.
+		latest, err := s.repository.GetById(ctx, o.ID)
+		if err != nil {
+			return err
+		}
+		*o = *latest
+		return nil
+	}
+	return err
+}
+
+func (s *orderService) Watch(ctx context.Context, accountId string) <-chan OrderEvent {
+	return s.events.watch(ctx, accountId)
+}
+
+func (s *orderService) GetByAccountId(ctx context.Context, accountId string, filter OrderFilter) (*OrderPage, error) {
+	if filter.Take == 0 || filter.Take > 100 {
+		filter.Take = 100
+	}
+
+	return s.repository.GetByAccountId(ctx, accountId, filter)
+}
+
+// CreatePromotion validates and stores a promotion. Codes are matched
+// case-insensitively and must be unique.
+func (s *orderService) CreatePromotion(ctx context.Context, p promotion.Promotion) (*promotion.Promotion, error) {
+	if err := validatePromotion(p); err != nil {
+		return nil, err
+	}
+
+	p.ID = ksuid.New().String()
+	p.UsageCount = 0
+	if err := s.repository.PutPromotion(ctx, p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *orderService) QuoteShipping(ctx context.Context, address Address, products []OrderedProduct) ([]shipping.Quote, error) {
+	return shipping.Quotes(s.shippingMethods, address.Country, shippingLines(products)), nil
+}
+
+func (s *orderService) CreateShipment(ctx context.Context, orderId, carrier string, lines []ShipmentLine) (*Shipment, error) {
+	adapter, ok := s.carriers[carrier]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+
+	o, err := s.repository.GetById(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != StatusPaid && o.Status != StatusFulfilled {
+		return nil, ErrNotShippable
+	}
+
+	shipments, err := s.repository.GetShipmentsByOrderId(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateShipmentLines(*o, shipments, lines); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	shipment := Shipment{
+		ID:        ksuid.New().String(),
+		OrderID:   orderId,
+		Carrier:   carrier,
+		Status:    ShipmentCreated,
+		Lines:     lines,
+		Events:    []TrackingEvent{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	shipment.TrackingNumber, err = adapter.CreateShipment(ctx, shipment, o.ShippingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.PutShipment(ctx, shipment); err != nil {
+		return nil, err
+	}
+	if err := s.advanceShipping(ctx, orderId); err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// validateShipmentLines checks that the lines are part of the order and
+// that their quantities are still left to ship. The repository checks the
+// quantities again when storing the shipment, against concurrent ones.
+func validateShipmentLines(o Order, shipments []Shipment, lines []ShipmentLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: no lines", ErrInvalidShipment)
+	}
+
+	ordered := map[string]int{}
+	for _, p := range o.Products {
+		ordered[p.ID] = p.Quantity
+	}
+	shipped := shippedQuantities(shipments)
+	seen := map[string]bool{}
+	for _, l := range lines {
+		if _, ok := ordered[l.ProductID]; !ok {
+			return fmt.Errorf("%w: product %s is not in the order", ErrInvalidShipment, l.ProductID)
+		}
+		if l.Quantity <= 0 || seen[l.ProductID] {
+			return fmt.Errorf("%w: invalid line for product %s", ErrInvalidShipment, l.ProductID)
+		}
+		seen[l.ProductID] = true
+		if shipped[l.ProductID]+l.Quantity > ordered[l.ProductID] {
+			return ErrOverShipped
+		}
+	}
+	return nil
+}
+
+func (s *orderService) GetShipments(ctx context.Context, orderId string) ([]Shipment, error) {
+	return s.repository.GetShipmentsByOrderId(ctx, orderId)
+}
+
+func (s *orderService) TrackShipments(ctx context.Context) error {
+	shipments, err := s.repository.GetActiveShipments(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sh := range shipments {
+		adapter, ok := s.carriers[sh.Carrier]
+		if !ok {
+			errs = append(errs, fmt.Errorf("shipment %s: %w %s", sh.ID, ErrUnknownCarrier, sh.Carrier))
+			continue
+		}
+
+		events, err := adapter.Track(ctx, sh.TrackingNumber)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shipment %s: %w", sh.ID, err))
+			continue
+		}
+		if len(events) <= len(sh.Events) {
+			continue
+		}
+
+		fresh := events[len(sh.Events):]
+		status := fresh[len(fresh)-1].Status
+		if err := s.repository.AddTrackingEvents(ctx, sh.ID, status, fresh); err != nil {
+			errs = append(errs, fmt.Errorf("shipment %s: %w", sh.ID, err))
+			continue
+		}
+		if status == ShipmentDelivered {
+			if err := s.advanceShipping(ctx, sh.OrderID); err != nil {
+				errs = append(errs, fmt.Errorf("order %s: %w", sh.OrderID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// advanceShipping moves the order along with its shipments: fulfilled once
+// a shipment exists, shipped once every line is in one and delivered once
+// all of them were delivered.
+func (s *orderService) advanceShipping(ctx context.Context, orderId string) error {
+	o, err := s.repository.GetById(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	shipments, err := s.repository.GetShipmentsByOrderId(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	allShipped, allDelivered := shipmentProgress(*o, shipments)
+	targets := []Status{StatusFulfilled}
+	if allShipped {
+		targets = append(targets, StatusShipped)
+	}
+	if allDelivered {
+		targets = append(targets, StatusDelivered)
+	}
+
+	for _, target := range targets {
+		if !o.Status.CanTransitionTo(target) {
+			continue
+		}
+		o, err = s.UpdateStatus(ctx, orderId, target, "shipping", "shipments "+string(target))
+		if errors.Is(err, ErrInvalidTransition) {
+			// Moved by a concurrent update, which will advance it.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shippingLines(products []OrderedProduct) []shipping.Line {
+	lines := make([]shipping.Line, 0, len(products))
+	for _, p := range products {
+		lines = append(lines, shipping.Line{Price: p.Price, Quantity: p.Quantity, Weight: p.Weight})
+	}
+	return lines
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func validatePromotion(p promotion.Promotion) error {
+	v := &grpcerr.ValidationError{Err: ErrInvalidPromotion}
+	if p.Name == "" {
+		v.Add("name", "is required")
+	}
+	if p.MinSubtotal < 0 {
+		v.Add("minSubtotal", "must not be negative")
+	}
+	if p.UsageLimit < 0 {
+		v.Add("usageLimit", "must not be negative")
+	}
+	if !p.StartsAt.IsZero() && !p.EndsAt.IsZero() && !p.EndsAt.After(p.StartsAt) {
+		v.Add("endsAt", "must be after startsAt")
+	}
+
+	switch p.Kind {
+	case promotion.KindPercentage:
+		if p.Percent <= 0 || p.Percent > 100 {
+			v.Add("percent", "must be in (0, 100]")
+		}
+	case promotion.KindFixedAmount:
+		if p.Amount <= 0 {
+			v.Add("amount", "must be positive")
+		}
+	case promotion.KindBuyXGetY:
+		if p.BuyQuantity <= 0 {
+			v.Add("buyQuantity", "must be positive")
+		}
+		if p.GetQuantity <= 0 {
+			v.Add("getQuantity", "must be positive")
+		}
+	case promotion.KindFreeShipping:
+	default:
+		v.Add("kind", fmt.Sprintf("unknown kind %q", p.Kind))
+	}
+	return v.OrNil()
 }
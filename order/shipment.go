@@ -0,0 +1,174 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrShipmentNotFound = errors.New("shipment not found")
+	ErrUnknownCarrier   = errors.New("unknown carrier")
+	ErrInvalidShipment  = errors.New("invalid shipment")
+	ErrOverShipped      = errors.New("shipment exceeds the quantities left to ship")
+	ErrNotShippable     = errors.New("order cannot be shipped in its current status")
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentCreated        ShipmentStatus = "created"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	ShipmentException      ShipmentStatus = "exception"
+)
+
+// Shipment is a parcel holding some of the lines of an order. An order may
+// be shipped in several of them.
+type Shipment struct {
+	ID             string
+	OrderID        string
+	Carrier        string
+	TrackingNumber string
+	Status         ShipmentStatus
+	Lines          []ShipmentLine
+	Events         []TrackingEvent
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type ShipmentLine struct {
+	ProductID string
+	Quantity  int
+}
+
+// TrackingEvent is a step reported by the carrier.
+type TrackingEvent struct {
+	Status      ShipmentStatus
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// CarrierAdapter talks to a carrier. Track returns every event of the
+// parcel so far, oldest first.
+type CarrierAdapter interface {
+	Name() string
+	CreateShipment(ctx context.Context, s Shipment, address Address) (trackingNumber string, err error)
+	Track(ctx context.Context, trackingNumber string) ([]TrackingEvent, error)
+}
+
+// FakeCarrier is a CarrierAdapter for development. Each call to Track
+// reveals one more event of its script, stamped with the current time.
+type FakeCarrier struct {
+	name   string
+	script []TrackingEvent
+
+	mu       sync.Mutex
+	next     int
+	revealed map[string][]TrackingEvent
+}
+
+// DefaultTrackingScript takes a parcel from pickup to delivery.
+var DefaultTrackingScript = []TrackingEvent{
+	{Status: ShipmentInTransit, Description: "Picked up by carrier", Location: "Origin facility"},
+	{Status: ShipmentInTransit, Description: "Arrived at sorting center", Location: "Hub"},
+	{Status: ShipmentOutForDelivery, Description: "Out for delivery", Location: "Local depot"},
+	{Status: ShipmentDelivered, Description: "Delivered", Location: "Destination"},
+}
+
+// NewFakeCarrier returns a fake carrier emitting the script, or
+// DefaultTrackingScript when none is given.
+func NewFakeCarrier(name string, script ...TrackingEvent) *FakeCarrier {
+	if len(script) == 0 {
+		script = DefaultTrackingScript
+	}
+	return &FakeCarrier{
+		name:     name,
+		script:   script,
+		revealed: map[string][]TrackingEvent{},
+	}
+}
+
+func (c *FakeCarrier) Name() string { return c.name }
+
+func (c *FakeCarrier) CreateShipment(ctx context.Context, s Shipment, address Address) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next++
+	trackingNumber := fmt.Sprintf("%s-%s-%d", c.name, s.ID, c.next)
+	c.revealed[trackingNumber] = []TrackingEvent{}
+	return trackingNumber, nil
+}
+
+func (c *FakeCarrier) Track(ctx context.Context, trackingNumber string) ([]TrackingEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events, ok := c.revealed[trackingNumber]
+	if !ok {
+		// Created before a restart, replay the script from the start.
+		events = []TrackingEvent{}
+	}
+	if len(events) < len(c.script) {
+		e := c.script[len(events)]
+		e.OccurredAt = time.Now().UTC()
+		events = append(events, e)
+	}
+	c.revealed[trackingNumber] = events
+	return append([]TrackingEvent(nil), events...), nil
+}
+
+// TrackShipments polls the carriers for the shipments in flight every
+// interval until the context is done.
+func TrackShipments(ctx context.Context, s Service, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.TrackShipments(ctx); err != nil {
+				slog.ErrorContext(ctx, "shipments not tracked", "error", err)
+			}
+		}
+	}
+}
+
+// shippedQuantities sums the quantities of every product over the shipments.
+func shippedQuantities(shipments []Shipment) map[string]int {
+	shipped := map[string]int{}
+	for _, s := range shipments {
+		for _, l := range s.Lines {
+			shipped[l.ProductID] += l.Quantity
+		}
+	}
+	return shipped
+}
+
+// shipmentProgress tells whether every line of the order is in a shipment,
+// and whether all of those were delivered.
+func shipmentProgress(o Order, shipments []Shipment) (allShipped, allDelivered bool) {
+	shipped := shippedQuantities(shipments)
+	allShipped = true
+	for _, p := range o.Products {
+		if shipped[p.ID] < p.Quantity {
+			allShipped = false
+		}
+	}
+
+	allDelivered = allShipped
+	for _, s := range shipments {
+		if s.Status != ShipmentDelivered {
+			allDelivered = false
+		}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package order
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("invalid order status")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPaid      Status = "paid"
+	StatusFulfilled Status = "fulfilled"
+	StatusShipped   Status = "shipped"
+	StatusDelivered Status = "delivered"
+	// StatusCancelling is held while the cancellation hooks give back what
+	// the order took, until it is cancelled.
+	StatusCancelling Status = "cancelling"
+	StatusCancelled  Status = "cancelled"
+	StatusRefunded   Status = "refunded"
+)
+
+// transitions lists every status an order may move to from a given status.
+// Cancelled and refunded are terminal. An order is fulfilled once its first
+// shipment is created, so from then on it can only be returned, not
+// cancelled. Customers cancel through cancelling, while an aborted checkout
+// undoes its own steps and cancels the order directly.
+var transitions = map[Status][]Status{
+	StatusPending:    {StatusPaid, StatusCancelling, StatusCancelled},
+	StatusPaid:       {StatusFulfilled, StatusCancelling, StatusCancelled, StatusRefunded},
+	StatusFulfilled:  {StatusShipped},
+	StatusShipped:    {StatusDelivered},
+	StatusDelivered:  {StatusRefunded},
+	StatusCancelling: {StatusCancelled},
+	StatusCancelled:  {},
+	StatusRefunded:   {},
+}
+
+// StatusChange is a single entry of an order's status history.
+// From is empty for the entry recorded when the order is placed.
+type StatusChange struct {
+	From      Status
+	To        Status
+	Actor     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+func ParseStatus(s string) (Status, error) {
+	status := Status(s)
+	if _, ok := transitions[status]; !ok {
+		return "", ErrInvalidStatus
+	}
+	return status, nil
+}
+
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package order
+
+import "testing"
+
+func TestStatusCanTransitionTo(t *testing.T) {
+	all := []Status{StatusPending, StatusPaid, StatusFulfilled, StatusShipped, StatusDelivered, StatusCancelling, StatusCancelled, StatusRefunded}
+	allowed := map[Status][]Status{
+		StatusPending:    {StatusPaid, StatusCancelling, StatusCancelled},
+		StatusPaid:       {StatusFulfilled, StatusCancelling, StatusCancelled, StatusRefunded},
+		StatusFulfilled:  {StatusShipped},
+		StatusShipped:    {StatusDelivered},
+		StatusDelivered:  {StatusRefunded},
+		StatusCancelling: {StatusCancelled},
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Status
+		wantErr bool
+	}{
+		{in: "pending", want: StatusPending},
+		{in: "refunded", want: StatusRefunded},
+		{in: "Pending", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatus(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStatus(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
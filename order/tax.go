@@ -0,0 +1,98 @@
+package order
+
+import (
+	"context"
+	"math"
+	"strings"
+)
+
+// Address is where an order is delivered. Country is an ISO 3166-1
+// alpha-2 code and Region a subdivision of it, such as a state.
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+// LineTax is the tax owed on one order line.
+type LineTax struct {
+	ProductID string
+	TaxClass  string
+	Rate      float64
+	Amount    float64
+}
+
+// TaxCalculator computes the tax of order lines shipped to an address.
+// Lines are taxed on their price net of discounts.
+type TaxCalculator interface {
+	Calculate(ctx context.Context, address Address, lines []OrderedProduct) ([]LineTax, error)
+}
+
+// TaxRate is one row of a tax table. An empty Region or TaxClass matches
+// any region or class of the country.
+type TaxRate struct {
+	Country  string
+	Region   string
+	TaxClass string
+	// Rate is a fraction, 0.2 for 20%.
+	Rate float64
+}
+
+type taxKey struct {
+	country, region, class string
+}
+
+type tableTaxCalculator struct {
+	rates map[taxKey]float64
+}
+
+// NewTableTaxCalculator looks rates up in the given table. The most
+// specific row wins, the region weighing more than the tax class; lines
+// no row matches are not taxed.
+func NewTableTaxCalculator(rates []TaxRate) TaxCalculator {
+	t := &tableTaxCalculator{rates: make(map[taxKey]float64, len(rates))}
+	for _, r := range rates {
+		t.rates[newTaxKey(r.Country, r.Region, r.TaxClass)] = r.Rate
+	}
+	return t
+}
+
+func newTaxKey(country, region, class string) taxKey {
+	return taxKey{
+		country: strings.ToUpper(country),
+		region:  strings.ToUpper(region),
+		class:   strings.ToLower(class),
+	}
+}
+
+func (t *tableTaxCalculator) Calculate(ctx context.Context, address Address, lines []OrderedProduct) ([]LineTax, error) {
+	taxes := make([]LineTax, 0, len(lines))
+	for _, l := range lines {
+		rate := t.rate(address, l.TaxClass)
+		net := l.Price*float64(l.Quantity) - l.Discount
+		taxes = append(taxes, LineTax{
+			ProductID: l.ID,
+			TaxClass:  l.TaxClass,
+			Rate:      rate,
+			Amount:    math.Round(net*rate*100) / 100,
+		})
+	}
+	return taxes, nil
+}
+
+func (t *tableTaxCalculator) rate(address Address, class string) float64 {
+	for _, k := range []taxKey{
+		newTaxKey(address.Country, address.Region, class),
+		newTaxKey(address.Country, address.Region, ""),
+		newTaxKey(address.Country, "", class),
+		newTaxKey(address.Country, "", ""),
+	} {
+		if rate, ok := t.rates[k]; ok {
+			return rate
+		}
+	}
+	return 0
+}
@@ -2,12 +2,215 @@ CREATE TABLE IF NOT EXISTS orders (
   id CHAR(27) PRIMARY KEY,
   created_at TIMESTAMP WITH TIME ZONE NOT NULL,
   account_id CHAR(27) NOT NULL,
-  total_price MONEY NOT NULL
+  subtotal MONEY NOT NULL DEFAULT 0,
+  discount_total MONEY NOT NULL DEFAULT 0,
+  tax_total MONEY NOT NULL DEFAULT 0,
+  total_price MONEY NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelling', 'cancelled', 'refunded')),
+  coupon_code VARCHAR(64) NOT NULL DEFAULT '',
+  free_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+  shipping_address JSONB NOT NULL DEFAULT '{}',
+  shipping_method VARCHAR(64) NOT NULL DEFAULT '',
+  shipping_cost MONEY NOT NULL DEFAULT 0
 );
 
+CREATE INDEX IF NOT EXISTS orders_account_id_created_at_idx ON orders (account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON orders (created_at);
+CREATE INDEX IF NOT EXISTS orders_cancelling_idx ON orders (id) WHERE status = 'cancelling';
+
 CREATE TABLE IF NOT EXISTS order_products (
   order_id CHAR(27) REFERENCES orders (id) ON DELETE CASCADE,
   product_id CHAR(27),
   quantity INT NOT NULL,
+  price MONEY NOT NULL DEFAULT 0,
+  discount MONEY NOT NULL DEFAULT 0,
+  tax_class VARCHAR(32) NOT NULL DEFAULT '',
+  tax_rate NUMERIC(6, 4) NOT NULL DEFAULT 0,
+  tax MONEY NOT NULL DEFAULT 0,
   PRIMARY KEY (product_id, order_id)
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+  id BIGSERIAL PRIMARY KEY,
+  order_id CHAR(27) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+  from_status VARCHAR(16),
+  to_status VARCHAR(16) NOT NULL,
+  actor VARCHAR(64) NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id);
+
+CREATE TABLE IF NOT EXISTS order_idempotency (
+  account_id CHAR(27) NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  fingerprint CHAR(64) NOT NULL,
+  order_id CHAR(27) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (account_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS order_sagas (
+  id CHAR(27) PRIMARY KEY,
+  order_id CHAR(27) NOT NULL,
+  state VARCHAR(16) NOT NULL
+    CHECK (state IN ('running', 'compensating', 'completed', 'aborted')),
+  step INT NOT NULL,
+  payload JSONB NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_sagas_pending_idx ON order_sagas (created_at)
+  WHERE state IN ('running', 'compensating');
+
+CREATE TABLE IF NOT EXISTS order_saga_attempts (
+  id BIGSERIAL PRIMARY KEY,
+  saga_id CHAR(27) NOT NULL REFERENCES order_sagas (id) ON DELETE CASCADE,
+  step VARCHAR(32) NOT NULL,
+  action VARCHAR(16) NOT NULL,
+  attempt INT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS promotions (
+  id CHAR(27) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  kind VARCHAR(16) NOT NULL
+    CHECK (kind IN ('percentage', 'fixed_amount', 'buy_x_get_y', 'free_shipping')),
+  code VARCHAR(64),
+  percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+  amount MONEY NOT NULL DEFAULT 0,
+  buy_quantity INT NOT NULL DEFAULT 0,
+  get_quantity INT NOT NULL DEFAULT 0,
+  product_ids TEXT[] NOT NULL DEFAULT '{}',
+  min_subtotal MONEY NOT NULL DEFAULT 0,
+  starts_at TIMESTAMP WITH TIME ZONE,
+  ends_at TIMESTAMP WITH TIME ZONE,
+  usage_limit INT NOT NULL DEFAULT 0,
+  usage_count INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS promotions_code_idx ON promotions (LOWER(code));
+
+CREATE TABLE IF NOT EXISTS order_discounts (
+  id BIGSERIAL PRIMARY KEY,
+  order_id CHAR(27) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+  promotion_id CHAR(27) NOT NULL REFERENCES promotions (id),
+  code VARCHAR(64) NOT NULL DEFAULT '',
+  kind VARCHAR(16) NOT NULL,
+  product_id CHAR(27),
+  amount MONEY NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_discounts_order_id_idx ON order_discounts (order_id);
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+  country CHAR(2) NOT NULL,
+  region VARCHAR(64) NOT NULL DEFAULT '',
+  tax_class VARCHAR(32) NOT NULL DEFAULT '',
+  rate NUMERIC(6, 4) NOT NULL CHECK (rate >= 0),
+  PRIMARY KEY (country, region, tax_class)
+);
+
+INSERT INTO tax_rates (country, region, tax_class, rate) VALUES
+  ('US', 'CA', '', 0.0725),
+  ('US', 'NY', '', 0.04),
+  ('GB', '', '', 0.20),
+  ('GB', '', 'reduced', 0.05),
+  ('GB', '', 'zero', 0),
+  ('DE', '', '', 0.19),
+  ('DE', '', 'reduced', 0.07)
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+  id VARCHAR(64) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  kind VARCHAR(16) NOT NULL
+    CHECK (kind IN ('flat_rate', 'weight_based', 'free_over')),
+  rate MONEY NOT NULL DEFAULT 0,
+  per_kg MONEY NOT NULL DEFAULT 0,
+  threshold MONEY NOT NULL DEFAULT 0,
+  countries TEXT[] NOT NULL DEFAULT '{}'
+);
+
+INSERT INTO shipping_methods (id, name, kind, rate, per_kg, threshold, countries) VALUES
+  ('standard', 'Standard', 'free_over', 4.99, 0, 50, '{}'),
+  ('express', 'Express', 'weight_based', 9.99, 1.50, 0, '{}'),
+  ('pickup', 'Pickup point', 'flat_rate', 2.99, 0, 0, '{US,GB,DE}')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS shipments (
+  id CHAR(27) PRIMARY KEY,
+  order_id CHAR(27) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+  carrier VARCHAR(64) NOT NULL,
+  tracking_number VARCHAR(128) NOT NULL,
+  status VARCHAR(32) NOT NULL
+    CHECK (status IN ('created', 'in_transit', 'out_for_delivery', 'delivered', 'exception')),
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS shipments_order_id_idx ON shipments (order_id);
+CREATE INDEX IF NOT EXISTS shipments_active_idx ON shipments (status) WHERE status <> 'delivered';
+
+CREATE TABLE IF NOT EXISTS shipment_lines (
+  shipment_id CHAR(27) NOT NULL REFERENCES shipments (id) ON DELETE CASCADE,
+  product_id CHAR(27) NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  PRIMARY KEY (shipment_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+  id BIGSERIAL PRIMARY KEY,
+  shipment_id CHAR(27) NOT NULL REFERENCES shipments (id) ON DELETE CASCADE,
+  status VARCHAR(32) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  location VARCHAR(255) NOT NULL DEFAULT '',
+  occurred_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS shipment_events_shipment_id_idx ON shipment_events (shipment_id);
+
+CREATE TABLE IF NOT EXISTS returns (
+  id CHAR(27) PRIMARY KEY,
+  order_id CHAR(27) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+  account_id CHAR(27) NOT NULL,
+  status VARCHAR(16) NOT NULL
+    CHECK (status IN ('requested', 'approved', 'rejected', 'received', 'refunding', 'refunded')),
+  reason TEXT NOT NULL DEFAULT '',
+  note TEXT NOT NULL DEFAULT '',
+  refund_amount MONEY NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS returns_order_id_idx ON returns (order_id);
+CREATE INDEX IF NOT EXISTS returns_refunding_idx ON returns (updated_at) WHERE status = 'refunding';
+
+CREATE TABLE IF NOT EXISTS return_lines (
+  return_id CHAR(27) NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+  product_id CHAR(27) NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  amount MONEY NOT NULL,
+  PRIMARY KEY (return_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL PRIMARY KEY,
+  event_id CHAR(27) NOT NULL UNIQUE,
+  event_type VARCHAR(64) NOT NULL,
+  envelope BYTEA NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  published_at TIMESTAMP WITH TIME ZONE,
+  failed_at TIMESTAMP WITH TIME ZONE,
+  error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id)
+  WHERE published_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,127 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/lichb0rn/go-microservices/grpcclient"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/payment/pb"
+	"google.golang.org/grpc"
+)
+
+type Client struct {
+	conn    *grpc.ClientConn
+	service pb.PaymentServiceClient
+}
+
+func NewClient(url string, cfg grpcclient.Config) (*Client, error) {
+	conn, err := grpcclient.Dial(url, "payment", cfg, "GetPayment", "GetPaymentsForOrder")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:    conn,
+		service: pb.NewPaymentServiceClient(conn),
+	}, nil
+}
+
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// Health fails unless the service reports itself serving.
+func (c *Client) Health(ctx context.Context) error {
+	return health.Downstream(c.conn)(ctx)
+}
+
+func (c *Client) Authorize(ctx context.Context, orderId, accountId string, amount float64, currency string) (*Payment, error) {
+	r, err := c.service.Authorize(ctx, &pb.AuthorizeRequest{
+		OrderId:   orderId,
+		AccountId: accountId,
+		Amount:    amount,
+		Currency:  currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := paymentFromProto(r.Payment)
+	return &p, nil
+}
+
+func (c *Client) Capture(ctx context.Context, id string, amount float64) (*Payment, error) {
+	r, err := c.service.Capture(ctx, &pb.CaptureRequest{Id: id, Amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	p := paymentFromProto(r.Payment)
+	return &p, nil
+}
+
+func (c *Client) Refund(ctx context.Context, id string, amount float64, idempotencyKey string) (*Payment, error) {
+	r, err := c.service.Refund(ctx, &pb.RefundRequest{Id: id, Amount: amount, IdempotencyKey: idempotencyKey})
+	if err != nil {
+		return nil, err
+	}
+	p := paymentFromProto(r.Payment)
+	return &p, nil
+}
+
+func (c *Client) Void(ctx context.Context, id string) (*Payment, error) {
+	r, err := c.service.Void(ctx, &pb.VoidRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	p := paymentFromProto(r.Payment)
+	return &p, nil
+}
+
+func (c *Client) GetOne(ctx context.Context, id string) (*Payment, error) {
+	r, err := c.service.GetPayment(ctx, &pb.GetPaymentRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	p := paymentFromProto(r.Payment)
+	return &p, nil
+}
+
+func (c *Client) GetByOrderId(ctx context.Context, orderId string) ([]Payment, error) {
+	r, err := c.service.GetPaymentsForOrder(ctx, &pb.GetPaymentsForOrderRequest{OrderId: orderId})
+	if err != nil {
+		return nil, err
+	}
+
+	payments := make([]Payment, 0, len(r.Payments))
+	for _, p := range r.Payments {
+		payments = append(payments, paymentFromProto(p))
+	}
+	return payments, nil
+}
+
+func paymentFromProto(p *pb.Payment) Payment {
+	payment := Payment{
+		ID:             p.Id,
+		OrderID:        p.OrderId,
+		AccountID:      p.AccountId,
+		Amount:         p.Amount,
+		Currency:       p.Currency,
+		Status:         Status(p.Status),
+		CapturedAmount: p.CapturedAmount,
+		RefundedAmount: p.RefundedAmount,
+	}
+	payment.CreatedAt.UnmarshalBinary(p.CreatedAt)
+	payment.UpdatedAt.UnmarshalBinary(p.UpdatedAt)
+
+	for _, a := range p.Attempts {
+		attempt := Attempt{
+			PaymentID:      p.Id,
+			Operation:      a.Operation,
+			Amount:         a.Amount,
+			Success:        a.Success,
+			Error:          a.Error,
+			IdempotencyKey: a.IdempotencyKey,
+		}
+		attempt.CreatedAt.UnmarshalBinary(a.CreatedAt)
+		payment.Attempts = append(payment.Attempts, attempt)
+	}
+	return payment
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
+	"github.com/lichb0rn/go-microservices/logging"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/payment"
+	"github.com/lichb0rn/go-microservices/tracing"
+	"github.com/tinrab/kit/retry"
+)
+
+type Config struct {
+	DatabaseURL string `envconfig:"DATABASE_URL"`
+	// Provider picks the payment provider. Only the fake one exists for now.
+	Provider string `envconfig:"PAYMENT_PROVIDER" default:"fake"`
+	// RequestTimeout bounds every call the service serves.
+	RequestTimeout time.Duration `envconfig:"REQUEST_TIMEOUT" default:"10s"`
+	// HealthInterval is how often the dependencies are checked.
+	HealthInterval time.Duration `envconfig:"HEALTH_INTERVAL" default:"5s"`
+	// ShutdownTimeout is how long the calls in flight get to finish on shutdown.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"8s"`
+	TraceExporter   string        `envconfig:"TRACE_EXPORTER" default:"none"`
+	// LogFormat is json or text, and LogLevel debug, info, warn or error.
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+	// MetricsAddr is where /metrics is served, apart from the gRPC port.
+	MetricsAddr string `envconfig:"METRICS_ADDR" default:":9090"`
+}
+
+func main() {
+	var cfg Config
+	err := envconfig.Process("", &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := logging.Setup("payment", cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	exporter, err := tracing.NewExporter(cfg.TraceExporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tracing.Configure("payment", exporter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var provider payment.PaymentProvider
+	switch cfg.Provider {
+	case "fake":
+		provider = payment.NewFakeProvider()
+	default:
+		log.Fatalf("unknown payment provider %q", cfg.Provider)
+	}
+
+	var repository payment.Repository
+	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
+		repository, err = payment.NewPostgresRepository(cfg.DatabaseURL)
+		if err != nil {
+			slog.WarnContext(ctx, "repository not ready, retrying", "error", err)
+		}
+		return
+	})
+
+	defer repository.Close()
+
+	checker := health.NewChecker(map[string]health.Check{
+		"postgres": repository.Ping,
+	})
+
+	// The background loops end before anything deferred above is closed.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := metrics.ListenAndServe(ctx, cfg.MetricsAddr); err != nil {
+			slog.ErrorContext(ctx, "metrics not served", "error", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checker.Run(ctx, cfg.HealthInterval)
+	}()
+
+	s := payment.NewService(repository, provider)
+	srv, err := payment.ListendGRPC(s, checker, interceptor.Default(cfg.RequestTimeout), 8080)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.InfoContext(ctx, "listening", "port", 8080)
+	if err := srv.Run(ctx, cfg.ShutdownTimeout); err != nil {
+		slog.ErrorContext(ctx, "server stopped", "error", err)
+	}
+	slog.InfoContext(ctx, "shutting down")
+	stop()
+}
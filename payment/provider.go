@@ -0,0 +1,86 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrDeclined            = errors.New("payment declined")
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+)
+
+// PaymentProvider moves money through an external processor. Every call is
+// keyed by the payment id or the reference returned by Authorize, so that
+// a retried call does not charge twice. A payment may be refunded several
+// times, so refunds are keyed by the caller's idempotency key as well.
+type PaymentProvider interface {
+	Authorize(ctx context.Context, paymentId string, amount float64, currency string) (reference string, err error)
+	Capture(ctx context.Context, reference string, amount float64) error
+	Refund(ctx context.Context, reference string, amount float64, idempotencyKey string) error
+	Void(ctx context.Context, reference string) error
+}
+
+// FakeProvider is a deterministic provider for local use. The cents of the
+// authorized amount pick the outcome, like test card numbers do:
+//
+//	.51 declines the authorization
+//	.52 times out, blocking until the context is done or Timeout elapses
+//
+// Any other amount is authorized, and captures, refunds and voids succeed.
+type FakeProvider struct {
+	Latency time.Duration
+	Timeout time.Duration
+}
+
+const (
+	fakeDeclineCents = 51
+	fakeTimeoutCents = 52
+)
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{Timeout: 10 * time.Second}
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, paymentId string, amount float64, currency string) (string, error) {
+	if err := p.wait(ctx, p.Latency); err != nil {
+		return "", err
+	}
+
+	switch int(math.Round(amount*100)) % 100 {
+	case fakeDeclineCents:
+		return "", ErrDeclined
+	case fakeTimeoutCents:
+		if err := p.wait(ctx, p.Timeout); err != nil {
+			return "", err
+		}
+		return "", ErrProviderUnavailable
+	}
+	return "fake_" + paymentId, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, reference string, amount float64) error {
+	return p.wait(ctx, p.Latency)
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string, amount float64, idempotencyKey string) error {
+	return p.wait(ctx, p.Latency)
+}
+
+func (p *FakeProvider) Void(ctx context.Context, reference string) error {
+	return p.wait(ctx, p.Latency)
+}
+
+func (p *FakeProvider) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ErrProviderUnavailable
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,255 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/lichb0rn/go-microservices/metrics"
+	"github.com/lichb0rn/go-microservices/tracing"
+)
+
+var (
+	ErrNotFound    = errors.New("payment not found")
+	ErrLivePayment = errors.New("order already has a live payment")
+)
+
+type Repository interface {
+	Close()
+	Ping(ctx context.Context) error
+	// Put stores a new payment. It fails with ErrLivePayment if the order
+	// has a payment that is neither declined, failed, refunded nor voided.
+	Put(ctx context.Context, p Payment) error
+	// Change locks the payment and passes it to change, which may call the
+	// provider. The payment as change left it is stored along with the
+	// attempt it returns, even if change fails or ctx is done by then, and
+	// returned with the error of change. Nothing is stored without an
+	// attempt. Changes of a payment wait for each other, so change always
+	// sees its latest state.
+	Change(ctx context.Context, id string, change func(p *Payment) (*Attempt, error)) (*Payment, error)
+	GetById(ctx context.Context, id string) (*Payment, error)
+	GetByOrderId(ctx context.Context, orderId string) ([]Payment, error)
+}
+
+type postgresRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRepository(url string) (Repository, error) {
+	db, err := tracing.OpenPostgres(url)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RegisterDB("payment", db)
+	return &postgresRepository{db}, nil
+}
+
+func (r *postgresRepository) Close() {
+	r.db.Close()
+}
+
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *postgresRepository) Put(ctx context.Context, p Payment) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO payments (id, order_id, account_id, amount, currency, status, reference, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		p.ID,
+		p.OrderID,
+		p.AccountID,
+		p.Amount,
+		p.Currency,
+		p.Status,
+		p.Reference,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrLivePayment
+	}
+	return err
+}
+
+func (r *postgresRepository) Change(ctx context.Context, id string, change func(p *Payment) (*Attempt, error)) (*Payment, error) {
+	// The transaction outlives ctx, so that the outcome of a call made to
+	// the provider is not lost with a caller that has gone.
+	tx, err := r.db.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	p, changeErr, err := r.change(ctx, tx, id, change)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p, changeErr
+}
+
+func (r *postgresRepository) change(ctx context.Context, tx *sql.Tx, id string, change func(p *Payment) (*Attempt, error)) (*Payment, error, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM payments WHERE id = $1 FOR UPDATE", id); err != nil {
+		return nil, nil, err
+	}
+	payments, err := list(ctx, tx, "p.id = $1", id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(payments) == 0 {
+		return nil, nil, ErrNotFound
+	}
+	p := &payments[0]
+
+	a, changeErr := change(p)
+	if a == nil {
+		return p, nil, changeErr
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE payments
+		SET status = $1, reference = $2, captured_amount = $3, refunded_amount = $4, updated_at = $5
+		WHERE id = $6`,
+		p.Status,
+		p.Reference,
+		p.CapturedAmount,
+		p.RefundedAmount,
+		p.UpdatedAt,
+		p.ID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO payment_attempts (payment_id, operation, amount, success, error, idempotency_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		a.PaymentID,
+		a.Operation,
+		a.Amount,
+		a.Success,
+		a.Error,
+		a.IdempotencyKey,
+		a.CreatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Attempts = append(p.Attempts, *a)
+	return p, changeErr, nil
+}
+
+func (r *postgresRepository) GetById(ctx context.Context, id string) (*Payment, error) {
+	payments, err := list(ctx, r.db, "p.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(payments) == 0 {
+		return nil, ErrNotFound
+	}
+	return &payments[0], nil
+}
+
+func (r *postgresRepository) GetByOrderId(ctx context.Context, orderId string) ([]Payment, error) {
+	return list(ctx, r.db, "p.order_id = $1", orderId)
+}
+
+// queryer is a *sql.DB or a *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// list returns the payments matching the condition, oldest first,
+// with their attempts.
+func list(ctx context.Context, q queryer, condition string, arg string) ([]Payment, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT
+		p.id,
+		p.order_id,
+		p.account_id,
+		p.amount::numeric::float8,
+		p.currency,
+		p.status,
+		p.reference,
+		p.captured_amount::numeric::float8,
+		p.refunded_amount::numeric::float8,
+		p.created_at,
+		p.updated_at,
+		a.operation,
+		a.amount::numeric::float8,
+		a.success,
+		a.error,
+		a.idempotency_key,
+		a.created_at
+		FROM payments p LEFT JOIN payment_attempts a ON (p.id = a.payment_id)
+		WHERE `+condition+`
+		ORDER BY p.created_at, p.id, a.id`,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p := Payment{}
+		var operation, attemptError, idempotencyKey sql.NullString
+		var amount sql.NullFloat64
+		var success sql.NullBool
+		var createdAt sql.NullTime
+		if err := rows.Scan(
+			&p.ID,
+			&p.OrderID,
+			&p.AccountID,
+			&p.Amount,
+			&p.Currency,
+			&p.Status,
+			&p.Reference,
+			&p.CapturedAmount,
+			&p.RefundedAmount,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&operation,
+			&amount,
+			&success,
+			&attemptError,
+			&idempotencyKey,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if len(payments) == 0 || payments[len(payments)-1].ID != p.ID {
+			payments = append(payments, p)
+		}
+		if operation.Valid {
+			last := &payments[len(payments)-1]
+			last.Attempts = append(last.Attempts, Attempt{
+				PaymentID:      p.ID,
+				Operation:      operation.String,
+				Amount:         amount.Float64,
+				Success:        success.Bool,
+				Error:          attemptError.String,
+				IdempotencyKey: idempotencyKey.String,
+				CreatedAt:      createdAt.Time,
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,126 @@
+//go:generate protoc --go_out=./ --go-grpc_out=./ payment.proto
+package payment
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/lichb0rn/go-microservices/grpcserver"
+	"github.com/lichb0rn/go-microservices/health"
+	"github.com/lichb0rn/go-microservices/interceptor"
+	"github.com/lichb0rn/go-microservices/payment/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type grpcServer struct {
+	pb.UnimplementedPaymentServiceServer
+	service Service
+}
+
+func ListendGRPC(s Service, checker *health.Checker, chain interceptor.Chain, port int) (*grpcserver.Server, error) {
+	server := grpc.NewServer(append(chain.ServerOptions(), grpcserver.KeepaliveEnforcement())...)
+	pb.RegisterPaymentServiceServer(server, &grpcServer{service: s})
+	checker.Register(server)
+	reflection.Register(server)
+	return grpcserver.New(server, checker, port)
+}
+
+func (s *grpcServer) Authorize(ctx context.Context, r *pb.AuthorizeRequest) (*pb.AuthorizeResponse, error) {
+	p, err := s.service.Authorize(ctx, r.OrderId, r.AccountId, r.Amount, r.Currency)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.AuthorizeResponse{Payment: paymentToProto(*p)}, nil
+}
+
+func (s *grpcServer) Capture(ctx context.Context, r *pb.CaptureRequest) (*pb.CaptureResponse, error) {
+	p, err := s.service.Capture(ctx, r.Id, r.Amount)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.CaptureResponse{Payment: paymentToProto(*p)}, nil
+}
+
+func (s *grpcServer) Refund(ctx context.Context, r *pb.RefundRequest) (*pb.RefundResponse, error) {
+	p, err := s.service.Refund(ctx, r.Id, r.Amount, r.IdempotencyKey)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.RefundResponse{Payment: paymentToProto(*p)}, nil
+}
+
+func (s *grpcServer) Void(ctx context.Context, r *pb.VoidRequest) (*pb.VoidResponse, error) {
+	p, err := s.service.Void(ctx, r.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.VoidResponse{Payment: paymentToProto(*p)}, nil
+}
+
+func (s *grpcServer) GetPayment(ctx context.Context, r *pb.GetPaymentRequest) (*pb.GetPaymentResponse, error) {
+	p, err := s.service.GetOne(ctx, r.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.GetPaymentResponse{Payment: paymentToProto(*p)}, nil
+}
+
+func (s *grpcServer) GetPaymentsForOrder(ctx context.Context, r *pb.GetPaymentsForOrderRequest) (*pb.GetPaymentsForOrderResponse, error) {
+	res, err := s.service.GetByOrderId(ctx, r.OrderId)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	payments := make([]*pb.Payment, 0, len(res))
+	for _, p := range res {
+		payments = append(payments, paymentToProto(p))
+	}
+	return &pb.GetPaymentsForOrderResponse{Payments: payments}, nil
+}
+
+func toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidAmount):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrDeclined), errors.Is(err, ErrInvalidState):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrProviderUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	slog.ErrorContext(ctx, "unexpected error", "error", err)
+	return err
+}
+
+func paymentToProto(p Payment) *pb.Payment {
+	pp := &pb.Payment{
+		Id:             p.ID,
+		OrderId:        p.OrderID,
+		AccountId:      p.AccountID,
+		Amount:         p.Amount,
+		Currency:       p.Currency,
+		Status:         string(p.Status),
+		CapturedAmount: p.CapturedAmount,
+		RefundedAmount: p.RefundedAmount,
+	}
+	pp.CreatedAt, _ = p.CreatedAt.MarshalBinary()
+	pp.UpdatedAt, _ = p.UpdatedAt.MarshalBinary()
+
+	for _, a := range p.Attempts {
+		attempt := &pb.Payment_Attempt{
+			Operation:      a.Operation,
+			Amount:         a.Amount,
+			Success:        a.Success,
+			Error:          a.Error,
+			IdempotencyKey: a.IdempotencyKey,
+		}
+		attempt.CreatedAt, _ = a.CreatedAt.MarshalBinary()
+		pp.Attempts = append(pp.Attempts, attempt)
+	}
+	return pp
+}
@@ -0,0 +1,309 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid payment amount")
+	ErrInvalidState  = errors.New("payment is not in a state allowing this operation")
+)
+
+type Status string
+
+const (
+	StatusPending           Status = "pending"
+	StatusAuthorized        Status = "authorized"
+	StatusDeclined          Status = "declined"
+	StatusFailed            Status = "failed"
+	StatusCaptured          Status = "captured"
+	StatusPartiallyRefunded Status = "partially_refunded"
+	StatusRefunded          Status = "refunded"
+	StatusVoided            Status = "voided"
+)
+
+type Service interface {
+	Authorize(ctx context.Context, orderId, accountId string, amount float64, currency string) (*Payment, error)
+	Capture(ctx context.Context, id string, amount float64) (*Payment, error)
+	Refund(ctx context.Context, id string, amount float64, idempotencyKey string) (*Payment, error)
+	Void(ctx context.Context, id string) (*Payment, error)
+	GetOne(ctx context.Context, id string) (*Payment, error)
+	GetByOrderId(ctx context.Context, orderId string) ([]Payment, error)
+}
+
+// Payment is a payment intent for an order. Attempts lists every call made
+// to the provider for it.
+type Payment struct {
+	ID             string
+	OrderID        string
+	AccountID      string
+	Amount         float64
+	Currency       string
+	Status         Status
+	Reference      string
+	CapturedAmount float64
+	RefundedAmount float64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Attempts       []Attempt
+}
+
+// Attempt is a call made to the provider. IdempotencyKey is the key the
+// caller gave to a refund, if any.
+type Attempt struct {
+	PaymentID      string
+	Operation      string
+	Amount         float64
+	Success        bool
+	Error          string
+	IdempotencyKey string
+	CreatedAt      time.Time
+}
+
+// RefundedWith sums the refunds made with the idempotency key.
+func (p Payment) RefundedWith(idempotencyKey string) float64 {
+	refunded := 0.0
+	for _, a := range p.Attempts {
+		if a.Operation == "refund" && a.Success && a.IdempotencyKey == idempotencyKey {
+			refunded += a.Amount
+		}
+	}
+	return refunded
+}
+
+type paymentService struct {
+	repository Repository
+	provider   PaymentProvider
+}
+
+func NewService(r Repository, p PaymentProvider) Service {
+	return &paymentService{repository: r, provider: p}
+}
+
+// Authorize reserves the amount for the order. An order has at most one
+// live payment, which retries and concurrent calls get back, so that the
+// order is not charged twice.
+func (s *paymentService) Authorize(ctx context.Context, orderId, accountId string, amount float64, currency string) (*Payment, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+
+	payments, err := s.repository.GetByOrderId(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if p := live(payments); p != nil {
+		return s.resume(ctx, p)
+	}
+
+	now := time.Now().UTC()
+	p := &Payment{
+		ID:        ksuid.New().String(),
+		OrderID:   orderId,
+		AccountID: accountId,
+		Amount:    amount,
+		Currency:  currency,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.repository.Put(ctx, *p)
+	if errors.Is(err, ErrLivePayment) {
+		// A concurrent call stored its payment first.
+		if payments, err = s.repository.GetByOrderId(ctx, orderId); err != nil {
+			return nil, err
+		}
+		if p := live(payments); p != nil {
+			return s.resume(ctx, p)
+		}
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.authorize(ctx, p.ID)
+}
+
+// live returns the payment that is authorized, or about to be.
+func live(payments []Payment) *Payment {
+	for i, p := range payments {
+		switch p.Status {
+		case StatusPending, StatusAuthorized, StatusCaptured, StatusPartiallyRefunded:
+			return &payments[i]
+		}
+	}
+	return nil
+}
+
+// resume completes the authorization of a pending payment, left so by a
+// concurrent call or one that died before storing the outcome.
+func (s *paymentService) resume(ctx context.Context, p *Payment) (*Payment, error) {
+	if p.Status != StatusPending {
+		return p, nil
+	}
+	return s.authorize(ctx, p.ID)
+}
+
+// authorize asks the provider to authorize a pending payment. The provider
+// keys authorizations by the payment id, so asking again for a payment
+// whose outcome was never stored does not reserve the amount twice.
+func (s *paymentService) authorize(ctx context.Context, id string) (*Payment, error) {
+	p, err := s.repository.Change(ctx, id, func(p *Payment) (*Attempt, error) {
+		if p.Status != StatusPending {
+			return nil, nil
+		}
+
+		reference, authErr := s.provider.Authorize(ctx, p.ID, p.Amount, p.Currency)
+		switch {
+		case authErr == nil:
+			p.Status = StatusAuthorized
+			p.Reference = reference
+		case errors.Is(authErr, ErrDeclined):
+			p.Status = StatusDeclined
+		default:
+			p.Status = StatusFailed
+		}
+		return attempt(p, "authorize", p.Amount, "", authErr), authErr
+	})
+	if err != nil || p == nil {
+		return p, err
+	}
+
+	// Authorized by another call in the meantime, or not at all.
+	switch p.Status {
+	case StatusDeclined:
+		return p, ErrDeclined
+	case StatusFailed:
+		return p, ErrProviderUnavailable
+	case StatusVoided:
+		return p, ErrInvalidState
+	}
+	return p, nil
+}
+
+// Capture takes the authorized amount, or part of it. Zero captures it all.
+func (s *paymentService) Capture(ctx context.Context, id string, amount float64) (*Payment, error) {
+	return s.repository.Change(ctx, id, func(p *Payment) (*Attempt, error) {
+		if p.Status == StatusCaptured && (amount == 0 || amount == p.CapturedAmount) {
+			return nil, nil
+		}
+		if p.Status != StatusAuthorized {
+			return nil, ErrInvalidState
+		}
+		if amount == 0 {
+			amount = p.Amount
+		}
+		if amount < 0 || amount > p.Amount {
+			return nil, ErrInvalidAmount
+		}
+
+		captureErr := s.provider.Capture(ctx, p.Reference, amount)
+		if captureErr == nil {
+			p.Status = StatusCaptured
+			p.CapturedAmount = amount
+		}
+		return attempt(p, "capture", amount, "", captureErr), captureErr
+	})
+}
+
+// Refund gives back part of the captured amount. Zero refunds all that is
+// left. A refund with the idempotency key of an earlier successful one
+// returns the payment as it is, so that it can be retried safely. Refunds
+// of a payment run one at a time, so together they never give back more
+// than was captured.
+func (s *paymentService) Refund(ctx context.Context, id string, amount float64, idempotencyKey string) (*Payment, error) {
+	return s.repository.Change(ctx, id, func(p *Payment) (*Attempt, error) {
+		if idempotencyKey != "" && p.RefundedWith(idempotencyKey) > 0 {
+			return nil, nil
+		}
+		if p.Status == StatusRefunded && amount == 0 {
+			return nil, nil
+		}
+		if p.Status != StatusCaptured && p.Status != StatusPartiallyRefunded {
+			return nil, ErrInvalidState
+		}
+		left := p.CapturedAmount - p.RefundedAmount
+		if amount == 0 {
+			amount = left
+		}
+		if amount < 0 || amount > left {
+			return nil, ErrInvalidAmount
+		}
+
+		refundErr := s.provider.Refund(ctx, p.Reference, amount, idempotencyKey)
+		if refundErr == nil {
+			p.RefundedAmount += amount
+			p.Status = StatusPartiallyRefunded
+			if p.RefundedAmount >= p.CapturedAmount {
+				p.Status = StatusRefunded
+			}
+		}
+		return attempt(p, "refund", amount, idempotencyKey, refundErr), refundErr
+	})
+}
+
+// Void releases an authorization that was not captured. A pending payment
+// is authorized first, since the provider may have placed a hold for it
+// before its outcome was lost. Payments that never got authorized have
+// nothing to release and are returned as they are.
+func (s *paymentService) Void(ctx context.Context, id string) (*Payment, error) {
+	p, err := s.repository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == StatusPending {
+		if p, err := s.authorize(ctx, id); p == nil {
+			return nil, err
+		}
+	}
+
+	return s.repository.Change(ctx, id, func(p *Payment) (*Attempt, error) {
+		switch p.Status {
+		case StatusVoided, StatusDeclined, StatusFailed:
+			return nil, nil
+		case StatusAuthorized:
+		default:
+			return nil, ErrInvalidState
+		}
+
+		voidErr := s.provider.Void(ctx, p.Reference)
+		if voidErr == nil {
+			p.Status = StatusVoided
+		}
+		return attempt(p, "void", p.Amount, "", voidErr), voidErr
+	})
+}
+
+func (s *paymentService) GetOne(ctx context.Context, id string) (*Payment, error) {
+	return s.repository.GetById(ctx, id)
+}
+
+func (s *paymentService) GetByOrderId(ctx context.Context, orderId string) ([]Payment, error) {
+	return s.repository.GetByOrderId(ctx, orderId)
+}
+
+// attempt describes a provider call made for the payment, which Change
+// stores together with the new state of the payment.
+func attempt(p *Payment, operation string, amount float64, idempotencyKey string, callErr error) *Attempt {
+	now := time.Now().UTC()
+	a := &Attempt{
+		PaymentID:      p.ID,
+		Operation:      operation,
+		Amount:         amount,
+		Success:        callErr == nil,
+		IdempotencyKey: idempotencyKey,
+		CreatedAt:      now,
+	}
+	if callErr != nil {
+		a.Error = callErr.Error()
+	}
+	p.UpdatedAt = now
+	return a
+}
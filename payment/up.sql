@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS payments (
+  id CHAR(27) PRIMARY KEY,
+  order_id CHAR(27) NOT NULL,
+  account_id CHAR(27) NOT NULL,
+  amount MONEY NOT NULL,
+  currency CHAR(3) NOT NULL,
+  status VARCHAR(24) NOT NULL
+    CHECK (status IN ('pending', 'authorized', 'declined', 'failed', 'captured', 'partially_refunded', 'refunded', 'voided')),
+  reference VARCHAR(255) NOT NULL DEFAULT '',
+  captured_amount MONEY NOT NULL DEFAULT 0,
+  refunded_amount MONEY NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payments_order_id_idx ON payments (order_id);
+
+-- An order has at most one live payment, so that it is not charged twice.
+CREATE UNIQUE INDEX IF NOT EXISTS payments_order_live_idx
+  ON payments (order_id)
+  WHERE status IN ('pending', 'authorized', 'captured', 'partially_refunded');
+
+CREATE TABLE IF NOT EXISTS payment_attempts (
+  id BIGSERIAL PRIMARY KEY,
+  payment_id CHAR(27) NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+  operation VARCHAR(16) NOT NULL,
+  amount MONEY NOT NULL,
+  success BOOLEAN NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  idempotency_key VARCHAR(64) NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS payment_attempts_refund_key_idx
+  ON payment_attempts (payment_id, idempotency_key)
+  WHERE operation = 'refund' AND success AND idempotency_key <> '';
@@ -0,0 +1,250 @@
+// Package promotion evaluates discount rules against the lines of an order.
+package promotion
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrCouponInactive      = errors.New("coupon is not active")
+	ErrCouponExhausted     = errors.New("coupon usage limit reached")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to this order")
+)
+
+type Kind string
+
+const (
+	KindPercentage   Kind = "percentage"
+	KindFixedAmount  Kind = "fixed_amount"
+	KindBuyXGetY     Kind = "buy_x_get_y"
+	KindFreeShipping Kind = "free_shipping"
+)
+
+// Promotion is a discount rule. Promotions without a code apply to every
+// eligible order, the others only when their coupon code is given.
+type Promotion struct {
+	ID   string
+	Name string
+	Kind Kind
+	Code string
+	// Percent is the share taken off by percentage promotions, 0 to 100.
+	Percent float64
+	// Amount is taken off the eligible lines by fixed amount promotions.
+	Amount float64
+	// BuyQuantity and GetQuantity make a buy X get Y promotion: out of
+	// every X+Y units of an eligible product, Y are free.
+	BuyQuantity int
+	GetQuantity int
+	// ProductIDs restricts the promotion to some products, empty means all.
+	ProductIDs []string
+	// MinSubtotal is the order subtotal from which the promotion applies.
+	MinSubtotal float64
+	// StartsAt and EndsAt bound the promotion in time. Zero values leave
+	// the window open on that side.
+	StartsAt time.Time
+	EndsAt   time.Time
+	// UsageLimit caps the number of orders using the promotion, 0 means
+	// unlimited. UsageCount is the number of orders that used it so far.
+	UsageLimit int
+	UsageCount int
+}
+
+// Line is an order line as seen by the rules.
+type Line struct {
+	ProductID string
+	Price     float64
+	Quantity  int
+}
+
+func (l Line) total() float64 {
+	return l.Price * float64(l.Quantity)
+}
+
+// Discount is the part of a promotion applied to one line. ProductID is
+// empty for discounts on the whole order, such as free shipping.
+type Discount struct {
+	PromotionID string
+	Code        string
+	Kind        Kind
+	ProductID   string
+	Amount      float64
+}
+
+type Result struct {
+	Discounts    []Discount
+	FreeShipping bool
+}
+
+// Total is the amount taken off the order by all discounts.
+func (r *Result) Total() float64 {
+	total := 0.0
+	for _, d := range r.Discounts {
+		total += d.Amount
+	}
+	return round(total)
+}
+
+// LineTotal is the amount taken off the line of the product.
+func (r *Result) LineTotal(productId string) float64 {
+	total := 0.0
+	for _, d := range r.Discounts {
+		if d.ProductID == productId {
+			total += d.Amount
+		}
+	}
+	return round(total)
+}
+
+// Active tells whether the promotion can be used at the given time.
+func (p Promotion) Active(now time.Time) bool {
+	if !p.StartsAt.IsZero() && now.Before(p.StartsAt) {
+		return false
+	}
+	if !p.EndsAt.IsZero() && !now.Before(p.EndsAt) {
+		return false
+	}
+	return p.UsageLimit == 0 || p.UsageCount < p.UsageLimit
+}
+
+func (p Promotion) eligible(productId string) bool {
+	return len(p.ProductIDs) == 0 || slices.Contains(p.ProductIDs, productId)
+}
+
+// Evaluate applies the active automatic promotions and the one of the
+// coupon, if any, to the lines. Promotions are applied in the given order,
+// each on what the previous ones left of a line, so that a line never goes
+// below zero. A coupon that cannot be used is an error rather than being
+// ignored, since the customer asked for it.
+func Evaluate(promotions []Promotion, lines []Line, coupon string, now time.Time) (*Result, error) {
+	subtotal := 0.0
+	remaining := make([]float64, len(lines))
+	for i, l := range lines {
+		remaining[i] = l.total()
+		subtotal += remaining[i]
+	}
+
+	result := &Result{Discounts: []Discount{}}
+	couponFound := false
+	for _, p := range promotions {
+		isCoupon := p.Code != ""
+		if isCoupon {
+			if coupon == "" || !strings.EqualFold(p.Code, coupon) {
+				continue
+			}
+			couponFound = true
+			if err := checkCoupon(p, now); err != nil {
+				return nil, err
+			}
+		} else if !p.Active(now) {
+			continue
+		}
+
+		if subtotal < p.MinSubtotal {
+			if isCoupon {
+				return nil, ErrCouponNotApplicable
+			}
+			continue
+		}
+
+		discounts := apply(p, lines, remaining)
+		if p.Kind == KindFreeShipping {
+			result.FreeShipping = true
+			discounts = append(discounts, Discount{PromotionID: p.ID, Code: p.Code, Kind: p.Kind})
+		}
+		if isCoupon && len(discounts) == 0 {
+			return nil, ErrCouponNotApplicable
+		}
+		result.Discounts = append(result.Discounts, discounts...)
+	}
+
+	if coupon != "" && !couponFound {
+		return nil, ErrCouponNotFound
+	}
+	return result, nil
+}
+
+func checkCoupon(p Promotion, now time.Time) error {
+	if !p.StartsAt.IsZero() && now.Before(p.StartsAt) || !p.EndsAt.IsZero() && !now.Before(p.EndsAt) {
+		return ErrCouponInactive
+	}
+	if p.UsageLimit != 0 && p.UsageCount >= p.UsageLimit {
+		return ErrCouponExhausted
+	}
+	return nil
+}
+
+// apply computes the discounts of one promotion per line and takes them
+// off remaining.
+func apply(p Promotion, lines []Line, remaining []float64) []Discount {
+	amounts := make([]float64, len(lines))
+
+	switch p.Kind {
+	case KindPercentage:
+		for i, l := range lines {
+			if p.eligible(l.ProductID) {
+				amounts[i] = remaining[i] * p.Percent / 100
+			}
+		}
+	case KindFixedAmount:
+		// The amount is spread over the eligible lines in proportion to
+		// what is left of them.
+		eligible := 0.0
+		for i, l := range lines {
+			if p.eligible(l.ProductID) {
+				eligible += remaining[i]
+			}
+		}
+		if eligible > 0 {
+			// The last eligible line takes the rounding remainder, so that
+			// the lines add up to the amount to the cent.
+			amount := round(math.Min(p.Amount, eligible))
+			last := -1
+			spread := 0.0
+			for i, l := range lines {
+				if p.eligible(l.ProductID) && remaining[i] > 0 {
+					amounts[i] = round(amount * remaining[i] / eligible)
+					spread += amounts[i]
+					last = i
+				}
+			}
+			if last >= 0 {
+				amounts[last] += amount - spread
+			}
+		}
+	case KindBuyXGetY:
+		if group := p.BuyQuantity + p.GetQuantity; p.GetQuantity > 0 && group > 0 {
+			for i, l := range lines {
+				if p.eligible(l.ProductID) {
+					free := l.Quantity / group * p.GetQuantity
+					amounts[i] = float64(free) * l.Price
+				}
+			}
+		}
+	}
+
+	discounts := []Discount{}
+	for i, l := range lines {
+		amount := round(math.Min(amounts[i], remaining[i]))
+		if amount <= 0 {
+			continue
+		}
+		remaining[i] -= amount
+		discounts = append(discounts, Discount{
+			PromotionID: p.ID,
+			Code:        p.Code,
+			Kind:        p.Kind,
+			ProductID:   l.ProductID,
+			Amount:      amount,
+		})
+	}
+	return discounts
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
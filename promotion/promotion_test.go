@@ -0,0 +1,236 @@
+package promotion
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lines := []Line{
+		{ProductID: "a", Price: 10, Quantity: 2},
+		{ProductID: "b", Price: 5, Quantity: 2},
+	}
+
+	tests := []struct {
+		name             string
+		promotions       []Promotion
+		lines            []Line
+		coupon           string
+		wantErr          error
+		wantDiscounts    []Discount
+		wantFreeShipping bool
+	}{
+		{
+			name:          "no promotions",
+			wantDiscounts: []Discount{},
+		},
+		{
+			name:       "percentage",
+			promotions: []Promotion{{ID: "p", Kind: KindPercentage, Percent: 10}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindPercentage, ProductID: "a", Amount: 2},
+				{PromotionID: "p", Kind: KindPercentage, ProductID: "b", Amount: 1},
+			},
+		},
+		{
+			name:       "percentage of some products",
+			promotions: []Promotion{{ID: "p", Kind: KindPercentage, Percent: 50, ProductIDs: []string{"b"}}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindPercentage, ProductID: "b", Amount: 5},
+			},
+		},
+		{
+			name:       "fixed amount spread over the lines",
+			promotions: []Promotion{{ID: "p", Kind: KindFixedAmount, Amount: 6}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "a", Amount: 4},
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "b", Amount: 2},
+			},
+		},
+		{
+			name:       "fixed amount rounding left to the last line",
+			promotions: []Promotion{{ID: "p", Kind: KindFixedAmount, Amount: 1}},
+			lines: []Line{
+				{ProductID: "a", Price: 1, Quantity: 1},
+				{ProductID: "b", Price: 1, Quantity: 1},
+				{ProductID: "c", Price: 1, Quantity: 1},
+			},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "a", Amount: 0.33},
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "b", Amount: 0.33},
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "c", Amount: 0.34},
+			},
+		},
+		{
+			name:       "fixed amount over the eligible lines",
+			promotions: []Promotion{{ID: "p", Kind: KindFixedAmount, Amount: 50, ProductIDs: []string{"b"}}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindFixedAmount, ProductID: "b", Amount: 10},
+			},
+		},
+		{
+			name:       "buy two get one",
+			promotions: []Promotion{{ID: "p", Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+			lines:      []Line{{ProductID: "a", Price: 3, Quantity: 7}, {ProductID: "b", Price: 5, Quantity: 2}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindBuyXGetY, ProductID: "a", Amount: 6},
+			},
+		},
+		{
+			name:             "free shipping",
+			promotions:       []Promotion{{ID: "p", Kind: KindFreeShipping, MinSubtotal: 30}},
+			wantDiscounts:    []Discount{{PromotionID: "p", Kind: KindFreeShipping}},
+			wantFreeShipping: true,
+		},
+		{
+			name:          "below the minimum subtotal",
+			promotions:    []Promotion{{ID: "p", Kind: KindPercentage, Percent: 10, MinSubtotal: 30.01}},
+			wantDiscounts: []Discount{},
+		},
+		{
+			name: "outside the window",
+			promotions: []Promotion{
+				{ID: "early", Kind: KindPercentage, Percent: 10, StartsAt: now.Add(time.Second)},
+				{ID: "ended", Kind: KindPercentage, Percent: 10, EndsAt: now},
+				{ID: "used up", Kind: KindPercentage, Percent: 10, UsageLimit: 3, UsageCount: 3},
+			},
+			wantDiscounts: []Discount{},
+		},
+		{
+			name:       "within the window",
+			promotions: []Promotion{{ID: "p", Kind: KindPercentage, Percent: 10, StartsAt: now, EndsAt: now.Add(time.Second), ProductIDs: []string{"a"}}},
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Kind: KindPercentage, ProductID: "a", Amount: 2},
+			},
+		},
+		{
+			name: "stacked on what is left",
+			promotions: []Promotion{
+				{ID: "auto", Kind: KindPercentage, Percent: 10, ProductIDs: []string{"a"}},
+				{ID: "coupon", Code: "SAVE5", Kind: KindFixedAmount, Amount: 5, ProductIDs: []string{"a"}},
+			},
+			coupon: "SAVE5",
+			wantDiscounts: []Discount{
+				{PromotionID: "auto", Kind: KindPercentage, ProductID: "a", Amount: 2},
+				{PromotionID: "coupon", Code: "SAVE5", Kind: KindFixedAmount, ProductID: "a", Amount: 5},
+			},
+		},
+		{
+			name: "stacked never below zero",
+			promotions: []Promotion{
+				{ID: "half", Kind: KindPercentage, Percent: 50},
+				{ID: "all", Kind: KindPercentage, Percent: 100},
+				{ID: "more", Kind: KindFixedAmount, Amount: 5},
+			},
+			wantDiscounts: []Discount{
+				{PromotionID: "half", Kind: KindPercentage, ProductID: "a", Amount: 10},
+				{PromotionID: "half", Kind: KindPercentage, ProductID: "b", Amount: 5},
+				{PromotionID: "all", Kind: KindPercentage, ProductID: "a", Amount: 10},
+				{PromotionID: "all", Kind: KindPercentage, ProductID: "b", Amount: 5},
+			},
+		},
+		{
+			name:          "coupon of another code left out",
+			promotions:    []Promotion{{ID: "p", Code: "OTHER", Kind: KindPercentage, Percent: 10}},
+			wantDiscounts: []Discount{},
+		},
+		{
+			name:       "coupon matched regardless of case",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, ProductIDs: []string{"b"}}},
+			coupon:     "save10",
+			wantDiscounts: []Discount{
+				{PromotionID: "p", Code: "SAVE10", Kind: KindPercentage, ProductID: "b", Amount: 1},
+			},
+		},
+		{
+			name:       "unknown coupon",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10}},
+			coupon:     "SAVE20",
+			wantErr:    ErrCouponNotFound,
+		},
+		{
+			name:       "coupon not started",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, StartsAt: now.Add(time.Hour)}},
+			coupon:     "SAVE10",
+			wantErr:    ErrCouponInactive,
+		},
+		{
+			name:       "coupon ended",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, EndsAt: now}},
+			coupon:     "SAVE10",
+			wantErr:    ErrCouponInactive,
+		},
+		{
+			name:       "coupon used up",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, UsageLimit: 1, UsageCount: 1}},
+			coupon:     "SAVE10",
+			wantErr:    ErrCouponExhausted,
+		},
+		{
+			name:       "coupon below the minimum subtotal",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, MinSubtotal: 100}},
+			coupon:     "SAVE10",
+			wantErr:    ErrCouponNotApplicable,
+		},
+		{
+			name:       "coupon for products not ordered",
+			promotions: []Promotion{{ID: "p", Code: "SAVE10", Kind: KindPercentage, Percent: 10, ProductIDs: []string{"z"}}},
+			coupon:     "SAVE10",
+			wantErr:    ErrCouponNotApplicable,
+		},
+		{
+			name: "coupon with nothing left to discount",
+			promotions: []Promotion{
+				{ID: "all", Kind: KindPercentage, Percent: 100},
+				{ID: "p", Code: "SAVE5", Kind: KindFixedAmount, Amount: 5},
+			},
+			coupon:  "SAVE5",
+			wantErr: ErrCouponNotApplicable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.lines
+			if l == nil {
+				l = lines
+			}
+
+			got, err := Evaluate(tt.promotions, l, tt.coupon, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Evaluate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(got.Discounts, tt.wantDiscounts) {
+				t.Errorf("discounts = %+v, want %+v", got.Discounts, tt.wantDiscounts)
+			}
+			if got.FreeShipping != tt.wantFreeShipping {
+				t.Errorf("free shipping = %t, want %t", got.FreeShipping, tt.wantFreeShipping)
+			}
+		})
+	}
+}
+
+func TestResultTotals(t *testing.T) {
+	r := &Result{Discounts: []Discount{
+		{ProductID: "a", Amount: 0.1},
+		{ProductID: "a", Amount: 0.2},
+		{ProductID: "b", Amount: 1.05},
+		{Kind: KindFreeShipping},
+	}}
+
+	if got := r.Total(); got != 1.35 {
+		t.Errorf("Total() = %v, want 1.35", got)
+	}
+	if got := r.LineTotal("a"); got != 0.3 {
+		t.Errorf("LineTotal(a) = %v, want 0.3", got)
+	}
+	if got := r.LineTotal("c"); got != 0 {
+		t.Errorf("LineTotal(c) = %v, want 0", got)
+	}
+}
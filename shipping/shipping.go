@@ -0,0 +1,113 @@
+// Package shipping quotes the cost of delivering order lines to a country.
+package shipping
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrMethodNotFound    = errors.New("shipping method not found")
+	ErrMethodUnavailable = errors.New("shipping method not available for this destination")
+)
+
+type Kind string
+
+const (
+	// KindFlatRate charges Rate whatever is shipped.
+	KindFlatRate Kind = "flat_rate"
+	// KindWeightBased charges Rate plus PerKg for every kilogram.
+	KindWeightBased Kind = "weight_based"
+	// KindFreeOver charges Rate, or nothing from a subtotal of Threshold.
+	KindFreeOver Kind = "free_over"
+)
+
+type Method struct {
+	ID        string
+	Name      string
+	Kind      Kind
+	Rate      float64
+	PerKg     float64
+	Threshold float64
+	// Countries lists the ISO 3166-1 alpha-2 codes shipped to, empty
+	// means everywhere.
+	Countries []string
+}
+
+// Line is an order line as seen by the rates.
+type Line struct {
+	Price    float64
+	Quantity int
+	// Weight is the weight of one unit in kilograms.
+	Weight float64
+}
+
+type Quote struct {
+	MethodID string
+	Name     string
+	Cost     float64
+}
+
+// Ships tells whether the method delivers to the country.
+func (m Method) Ships(country string) bool {
+	return len(m.Countries) == 0 || slices.ContainsFunc(m.Countries, func(c string) bool {
+		return strings.EqualFold(c, country)
+	})
+}
+
+// Cost is what the method charges for the lines.
+func (m Method) Cost(lines []Line) float64 {
+	subtotal, weight := 0.0, 0.0
+	for _, l := range lines {
+		subtotal += l.Price * float64(l.Quantity)
+		weight += l.Weight * float64(l.Quantity)
+	}
+
+	cost := m.Rate
+	switch m.Kind {
+	case KindWeightBased:
+		cost += m.PerKg * weight
+	case KindFreeOver:
+		if subtotal >= m.Threshold {
+			cost = 0
+		}
+	}
+	return math.Round(cost*100) / 100
+}
+
+// Quotes returns a quote for every method delivering to the country,
+// cheapest first.
+func Quotes(methods []Method, country string, lines []Line) []Quote {
+	quotes := []Quote{}
+	for _, m := range methods {
+		if m.Ships(country) {
+			quotes = append(quotes, Quote{MethodID: m.ID, Name: m.Name, Cost: m.Cost(lines)})
+		}
+	}
+	slices.SortStableFunc(quotes, func(a, b Quote) int {
+		switch {
+		case a.Cost < b.Cost:
+			return -1
+		case a.Cost > b.Cost:
+			return 1
+		}
+		return 0
+	})
+	return quotes
+}
+
+// Find returns the quote of one method for the lines shipped to the country.
+func Find(methods []Method, id, country string, lines []Line) (*Quote, error) {
+	for _, m := range methods {
+		if m.ID != id {
+			continue
+		}
+		if !m.Ships(country) {
+			return nil, ErrMethodUnavailable
+		}
+		return &Quote{MethodID: m.ID, Name: m.Name, Cost: m.Cost(lines)}, nil
+	}
+	return nil, ErrMethodNotFound
+}
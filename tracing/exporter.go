@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Exporter receives the spans once they end.
+type Exporter interface {
+	Export(s SpanData)
+}
+
+// StdoutExporter writes every span as a line of JSON.
+type StdoutExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{enc: json.NewEncoder(w)}
+}
+
+func (e *StdoutExporter) Export(s SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enc.Encode(s)
+}
+
+// InMemoryExporter keeps the last spans, up to its limit, for tools and
+// checks to look at.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	limit int
+	spans []SpanData
+}
+
+func NewInMemoryExporter(limit int) *InMemoryExporter {
+	return &InMemoryExporter{limit: limit}
+}
+
+func (e *InMemoryExporter) Export(s SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, s)
+	if e.limit > 0 && len(e.spans) > e.limit {
+		e.spans = e.spans[len(e.spans)-e.limit:]
+	}
+}
+
+// Spans returns the spans kept, oldest first.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// NewExporter returns the exporter of the given name: "stdout", "memory",
+// or "none" and "" for no exporter.
+func NewExporter(name string) (Exporter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return NewStdoutExporter(os.Stdout), nil
+	case "memory":
+		return NewInMemoryExporter(1000), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", name)
+	}
+}
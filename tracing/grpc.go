@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor traces every call served, within the trace of
+// the caller when its metadata carries one.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isHealthCheck(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, span := startServer(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endCall(span, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isHealthCheck(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, span := startServer(ss.Context(), info.FullMethod)
+		err := handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
+		endCall(span, err)
+		return err
+	}
+}
+
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// isHealthCheck tells the calls of health checks, made every few seconds,
+// which are not worth a trace each.
+func isHealthCheck(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
+
+func startServer(ctx context.Context, method string) (context.Context, *Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(TraceparentHeader); len(values) > 0 {
+			if sc, err := ParseTraceparent(values[0]); err == nil {
+				ctx = ContextWithRemote(ctx, sc)
+			}
+		}
+	}
+	ctx, span := Start(ctx, method, KindServer)
+	span.SetAttribute("rpc.method", method)
+	return ctx, span
+}
+
+// UnaryClientInterceptor traces every call made and sends the trace
+// context along.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if isHealthCheck(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, span := startClient(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endCall(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor sends the trace context along with streaming
+// calls. Their span only covers opening the stream.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := startClient(ctx, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		endCall(span, err)
+		return stream, err
+	}
+}
+
+func startClient(ctx context.Context, method string) (context.Context, *Span) {
+	ctx, span := Start(ctx, method, KindClient)
+	span.SetAttribute("rpc.method", method)
+	ctx = metadata.AppendToOutgoingContext(ctx, TraceparentHeader, span.SpanContext().Traceparent())
+	return ctx, span
+}
+
+func endCall(span *Span, err error) {
+	span.SetAttribute("rpc.code", status.Code(err).String())
+	span.End(err)
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Middleware traces every request, within the trace of the caller when its
+// traceparent header carries one.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+			ctx = ContextWithRemote(ctx, sc)
+		}
+		ctx, span := Start(ctx, r.Method+" "+r.URL.Path, KindServer)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.path", r.URL.Path)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttribute("http.status", strconv.Itoa(sw.status))
+		span.End(nil)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack hands the connection over, for subscriptions over websockets.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Transport traces the requests sent through base, nil for the default
+// transport, and sends the trace context along. Like SQL statements, only
+// the requests sent within a trace are traced.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if _, ok := SpanContextFromContext(r.Context()); !ok {
+		return t.base.RoundTrip(r)
+	}
+
+	ctx, span := Start(r.Context(), r.Method+" "+r.URL.Path, KindClient)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.URL.Redacted())
+
+	r = r.Clone(ctx)
+	r.Header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+
+	res, err := t.base.RoundTrip(r)
+	if res != nil {
+		span.SetAttribute("http.status", strconv.Itoa(res.StatusCode))
+	}
+	span.End(err)
+	return res, err
+}
@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// statementLength bounds the statements recorded on SQL spans.
+const statementLength = 500
+
+// WrapConnector traces the statements run over the connections of c, to
+// be opened with sql.OpenDB.
+func WrapConnector(c driver.Connector) driver.Connector {
+	return &connector{Connector: c}
+}
+
+// OpenPostgres opens a database whose statements are traced.
+func OpenPostgres(url string) (*sql.DB, error) {
+	c, err := pq.NewConnector(url)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(WrapConnector(c)), nil
+}
+
+type connector struct {
+	driver.Connector
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn}, nil
+}
+
+// conn traces the queries and statements of a driver connection. It only
+// offers the context-aware interfaces of the driver it wraps; without
+// them, database/sql falls back to prepared statements, traced too.
+type conn struct {
+	driver.Conn
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startSQL(ctx, "sql.query", query)
+	rows, err := q.QueryContext(ctx, query, args)
+	endSQL(span, err)
+	return rows, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startSQL(ctx, "sql.exec", query)
+	res, err := e.ExecContext(ctx, query, args)
+	endSQL(span, err)
+	return res, err
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		st  driver.Stmt
+		err error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = p.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: st, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+type stmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	span := startSQL(ctx, "sql.exec", s.query)
+	var (
+		res driver.Result
+		err error
+	)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	endSQL(span, err)
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	span := startSQL(ctx, "sql.query", s.query)
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	endSQL(span, err)
+	return rows, err
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, 0, len(args))
+	for _, a := range args {
+		vs = append(vs, a.Value)
+	}
+	return vs
+}
+
+// startSQL traces a statement run within a trace. The ones run outside of
+// any, such as by background loops, would each make a trace of their own
+// and are left out.
+func startSQL(ctx context.Context, name, query string) *Span {
+	if _, ok := SpanContextFromContext(ctx); !ok {
+		return nil
+	}
+	_, span := Start(ctx, name, KindClient)
+	span.SetAttribute("db.statement", truncate(query, statementLength))
+	return span
+}
+
+func endSQL(span *Span, err error) {
+	if span == nil {
+		return
+	}
+	if errors.Is(err, driver.ErrSkip) {
+		err = nil
+	}
+	span.End(err)
+}
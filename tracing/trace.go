@@ -0,0 +1,268 @@
+// Package tracing follows a request across the gateway and the services.
+// Trace contexts travel in W3C traceparent headers, over HTTP and in gRPC
+// metadata, and finished spans go to an Exporter.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidTraceparent = errors.New("invalid traceparent")
+)
+
+// TraceparentHeader is the name of the header, or metadata key, carrying
+// the trace context.
+const TraceparentHeader = "traceparent"
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span within its trace. Spans that are not
+// sampled are propagated but not exported.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats the span context as a version 00 traceparent.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent reads a traceparent. Versions after 00 are read as 00,
+// as the specification asks.
+func ParseTraceparent(s string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, err
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, err
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return ErrInvalidTraceparent
+	}
+	return nil
+}
+
+// Kinds of spans, telling the side of a call they stand for.
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// SpanData is a finished span as exported.
+type SpanData struct {
+	Service    string            `json:"service"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentId,omitempty"`
+	Start      time.Time         `json:"start"`
+	Duration   time.Duration     `json:"duration"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Span is an operation being traced.
+type Span struct {
+	mu    sync.Mutex
+	ctx   SpanContext
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.ctx
+}
+
+// SetAttribute records a detail of the operation.
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]string{}
+	}
+	s.data.Attributes[key] = value
+}
+
+// End finishes the span, failed if err is not nil, and exports it. Only
+// the first call counts.
+func (s *Span) End(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.Duration = time.Since(s.data.Start)
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	s.mu.Unlock()
+
+	if !s.ctx.Sampled {
+		return
+	}
+	if e := exporter(); e != nil {
+		e.Export(data)
+	}
+}
+
+type spanCtxKey struct{}
+type remoteCtxKey struct{}
+
+// SpanFromContext returns the span the context is within, if any.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemote marks the context as within a span of another
+// process, such as the caller of a request, for the spans started from it.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteCtxKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context to propagate from ctx:
+// the one of its span, or else of the remote span it is within.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.ctx, true
+	}
+	sc, ok := ctx.Value(remoteCtxKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Start starts a span of the given kind as a child of the span ctx is
+// within, or as the root of a new trace.
+func Start(ctx context.Context, name, kind string) (context.Context, *Span) {
+	s := &Span{ctx: SpanContext{Sampled: true}}
+	parent, hasParent := SpanContextFromContext(ctx)
+	if hasParent {
+		s.ctx.TraceID = parent.TraceID
+		s.ctx.Sampled = parent.Sampled
+	} else {
+		s.ctx.TraceID = newTraceID()
+	}
+	s.ctx.SpanID = newSpanID()
+
+	s.data = SpanData{
+		Service: serviceName(),
+		Name:    name,
+		Kind:    kind,
+		TraceID: s.ctx.TraceID.String(),
+		SpanID:  s.ctx.SpanID.String(),
+		Start:   time.Now(),
+	}
+	if hasParent {
+		s.data.ParentID = parent.SpanID.String()
+	}
+	return context.WithValue(ctx, spanCtxKey{}, s), s
+}
+
+func newTraceID() TraceID {
+	var t TraceID
+	for t == (TraceID{}) {
+		binary.BigEndian.PutUint64(t[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(t[8:], rand.Uint64())
+	}
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for s == (SpanID{}) {
+		binary.BigEndian.PutUint64(s[:], rand.Uint64())
+	}
+	return s
+}
+
+var global struct {
+	mu       sync.RWMutex
+	service  string
+	exporter Exporter
+}
+
+// Configure names the service the spans of this process belong to and
+// sets where they go. A nil exporter drops them, while trace contexts are
+// still propagated.
+func Configure(service string, e Exporter) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+
+	global.service = service
+	global.exporter = e
+}
+
+func serviceName() string {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	return global.service
+}
+
+func exporter() Exporter {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	return global.exporter
+}
+
+// truncate shortens long attribute values, such as SQL statements.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:n], len(s))
+}
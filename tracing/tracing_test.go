@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const echoMethod = "/tracing.test.Echo/Echo"
+
+// echoDesc describes a service with a single unary method, served by
+// echoHandler, so that calls go through the interceptors like generated
+// services do.
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "tracing.test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Echo", Handler: echoHandler}},
+}
+
+func echoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &durationpb.Duration{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := Start(ctx, "echo", KindInternal)
+		span.End(nil)
+		return req, nil
+	}
+	return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: echoMethod}, handler)
+}
+
+// startGateway serves an HTTP handler behind Middleware that calls the
+// echo service through the gRPC interceptors, like the gateway calls the
+// services.
+func startGateway(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(UnaryServerInterceptor()))
+	server.RegisterService(&echoDesc, struct{}{})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	gateway := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := conn.Invoke(r.Context(), echoMethod, &durationpb.Duration{}, &durationpb.Duration{}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	})))
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func configureInMemory(t *testing.T) *InMemoryExporter {
+	t.Helper()
+	exporter := NewInMemoryExporter(0)
+	Configure("test", exporter)
+	t.Cleanup(func() { Configure("", nil) })
+	return exporter
+}
+
+func TestSpansFollowTheRequest(t *testing.T) {
+	remote := SpanContext{
+		TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Sampled: true,
+	}
+
+	tests := []struct {
+		name        string
+		traceparent string
+	}{
+		{name: "new trace"},
+		{name: "within the caller's trace", traceparent: remote.Traceparent()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := configureInMemory(t)
+			gateway := startGateway(t)
+
+			req, err := http.NewRequest(http.MethodPost, gateway.URL+"/graphql", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.traceparent != "" {
+				req.Header.Set(TraceparentHeader, tt.traceparent)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+			}
+
+			spans := map[string]SpanData{}
+			for _, s := range exporter.Spans() {
+				spans[s.Kind+" "+s.Name] = s
+			}
+			httpSpan := spans["server POST /graphql"]
+			clientSpan := spans["client "+echoMethod]
+			serverSpan := spans["server "+echoMethod]
+			echoSpan := spans["internal echo"]
+			if len(spans) != 4 || httpSpan.SpanID == "" || clientSpan.SpanID == "" || serverSpan.SpanID == "" || echoSpan.SpanID == "" {
+				t.Fatalf("spans = %v, want the HTTP, gRPC client, gRPC server and echo spans", exporter.Spans())
+			}
+
+			wantRoot := ""
+			if tt.traceparent != "" {
+				wantRoot = remote.SpanID.String()
+				if httpSpan.TraceID != remote.TraceID.String() {
+					t.Errorf("trace = %s, want the caller's %s", httpSpan.TraceID, remote.TraceID)
+				}
+			}
+			parents := []struct {
+				span   SpanData
+				parent string
+			}{
+				{httpSpan, wantRoot},
+				{clientSpan, httpSpan.SpanID},
+				{serverSpan, clientSpan.SpanID},
+				{echoSpan, serverSpan.SpanID},
+			}
+			for _, p := range parents {
+				if p.span.ParentID != p.parent {
+					t.Errorf("%s %s has parent %q, want %q", p.span.Kind, p.span.Name, p.span.ParentID, p.parent)
+				}
+				if p.span.TraceID != httpSpan.TraceID {
+					t.Errorf("%s %s is in trace %s, want %s", p.span.Kind, p.span.Name, p.span.TraceID, httpSpan.TraceID)
+				}
+			}
+			if got := clientSpan.Attributes["rpc.code"]; got != "OK" {
+				t.Errorf("rpc.code = %q, want OK", got)
+			}
+			if got := httpSpan.Attributes["http.status"]; got != "200" {
+				t.Errorf("http.status = %q, want 200", got)
+			}
+		})
+	}
+}
+
+func TestUnsampledTraceIsNotExported(t *testing.T) {
+	exporter := configureInMemory(t)
+	gateway := startGateway(t)
+
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	req, err := http.NewRequest(http.MethodPost, gateway.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(TraceparentHeader, sc.Traceparent())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Errorf("spans = %v, want none", spans)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		in          string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{in: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-later", wantSampled: true},
+		{in: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-01", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceparent() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if sc.Sampled != tt.wantSampled {
+				t.Errorf("sampled = %t, want %t", sc.Sampled, tt.wantSampled)
+			}
+			if tt.in[:2] == "00" && sc.Traceparent() != tt.in {
+				t.Errorf("Traceparent() = %s, want %s", sc.Traceparent(), tt.in)
+			}
+		})
+	}
+}